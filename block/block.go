@@ -0,0 +1,180 @@
+package block
+
+import (
+        "time"
+        "bytes"
+        "crypto/sha256"
+        "encoding/binary"
+        "fmt"
+        "log"
+        "math"
+        "sort"
+
+        "blockchain_go/transaction"
+    )
+
+const blockVersion = 1
+
+// Header holds the fields proof-of-work is computed on. The transactions are only
+// committed to through their Merkle root, so headers can be hashed and synced on their own.
+type Header struct {
+    Version       int32
+    PrevBlockHash []byte
+    MerkleRoot    []byte
+    Timestamp     int64
+    Bits          uint32
+    Nonce         int
+}
+
+// Block represents a block in the blockchain
+type Block struct {
+    Header
+    Transactions   []*transaction.Transaction
+    Hash          []byte
+    Height        int
+}
+
+
+// NewBlock creates and returns Block, mined at the difficulty given by bits
+func NewBlock(transactions []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32) *Block {
+    return NewBlockAt(transactions, prevBlockHash, height, bits, time.Now().Unix())
+}
+
+// NewBlockAt is NewBlock with the timestamp to put in the header
+func NewBlockAt(transactions []*transaction.Transaction, prevBlockHash []byte, height int, bits uint32, timestamp int64) *Block {
+    header := Header{blockVersion, prevBlockHash, nil, timestamp, bits, 0}
+    block := &Block{header, transactions, []byte{}, height} // []byte can be initiled by string
+    block.MerkleRoot = block.HashTransactions()
+    pow := NewProofOfWork(block)  // obtain a pow struct which contains block pointer and target
+    nonce, hash := pow.Run()
+
+    block.Hash = hash[:]
+    block.Nonce = nonce
+
+    return block
+}
+// NewGenesisBlock creates and returns genesis Block
+func NewGenesisBlock(coinbase *transaction.Transaction) *Block {
+    return NewBlock([]*transaction.Transaction{coinbase}, []byte{}, 0, BigToCompact(PowLimit))  // Genesis Block's preBlockHash must be []byte{}
+}
+
+// MedianTime returns the median of the timestamps. A block's timestamp has to be past the
+// median of the MedianTimeBlocks before it, so a single miner's clock can't drag the chain's time back.
+func MedianTime(timestamps []int64) int64 {
+    sorted := append([]int64{}, timestamps...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+    return sorted[len(sorted)/2]
+}
+
+// HashTransactions returns a hash of the transactions in the block
+func (b *Block) HashTransactions() []byte {
+    var transactions [][]byte
+
+    for _, tx := range b.Transactions {
+        transactions = append(transactions, tx.Serialize())
+    }
+    mTree := NewMerkleTree(transactions) 
+    return mTree.RootNode.Data
+}
+
+
+// Serialize encodes the header into its fixed 88 byte form, the data that gets hashed.
+// Integers are little-endian like in transactions, hashes are written as 32 bytes,
+// the genesis block's empty parent hash as zeros.
+func (h *Header) Serialize() []byte {
+    var result bytes.Buffer
+    var prevBlockHash, merkleRoot [32]byte
+
+    copy(prevBlockHash[:], h.PrevBlockHash)
+    copy(merkleRoot[:], h.MerkleRoot)
+    fields := []interface{}{h.Version, prevBlockHash, merkleRoot, h.Timestamp, h.Bits, int64(h.Nonce)}
+    for _, field := range fields {
+            err := binary.Write(&result, binary.LittleEndian, field)
+            if err != nil {
+                    log.Panic(err)
+            }
+    }
+    return result.Bytes()
+}
+
+// Hash returns the hash of the header, which is the hash of the block
+func (h *Header) Hash() []byte {
+    hash := sha256.Sum256(h.Serialize())
+
+    return hash[:]
+}
+
+// Serialize encodes the block as its 88 byte header, a varint height, a varint
+// transaction count and the transactions one after the other. The hash isn't stored,
+// it's the hash of the header.
+func (b *Block) Serialize() []byte {
+    var result bytes.Buffer
+    var varint [binary.MaxVarintLen64]byte
+
+    result.Write(b.Header.Serialize())
+    result.Write(varint[:binary.PutUvarint(varint[:], uint64(b.Height))])
+    result.Write(varint[:binary.PutUvarint(varint[:], uint64(len(b.Transactions)))])
+    for _, tx := range b.Transactions {
+        result.Write(tx.Serialize())
+    }
+    return result.Bytes()
+}
+
+// DeserializeBlock decodes a block, failing on data that isn't exactly one
+func DeserializeBlock(d []byte) (*Block, error) {
+    block, err := readBlock(bytes.NewReader(d))
+    if err != nil {
+            return nil, fmt.Errorf("can't decode block: %s", err)
+    }
+    return block, nil
+}
+
+func readBlock(r *bytes.Reader) (*Block, error) {
+    var header Header
+    var prevBlockHash, merkleRoot [32]byte
+    var nonce int64
+
+    fields := []interface{}{&header.Version, &prevBlockHash, &merkleRoot, &header.Timestamp, &header.Bits, &nonce}
+    for _, field := range fields {
+        err := binary.Read(r, binary.LittleEndian, field)
+        if err != nil {
+            return nil, err
+        }
+    }
+    header.Nonce = int(nonce)
+    header.MerkleRoot = merkleRoot[:]
+    header.PrevBlockHash = []byte{}
+    if prevBlockHash != [32]byte{} {
+        header.PrevBlockHash = prevBlockHash[:]
+    }
+
+    height, err := binary.ReadUvarint(r)
+    if err != nil {
+        return nil, err
+    }
+    if height > math.MaxInt32 {
+        return nil, fmt.Errorf("height %d out of range", height)
+    }
+    count, err := binary.ReadUvarint(r)
+    if err != nil {
+        return nil, err
+    }
+    // a transaction takes at least a byte, so a count past what's left is bogus
+    if count > uint64(r.Len()) {
+        return nil, fmt.Errorf("transaction count %d is more than the data holds", count)
+    }
+
+    block := &Block{Header: header, Hash: header.Hash(), Height: int(height)}
+    for i := uint64(0); i < count; i++ {
+        tx, err := transaction.ReadTransaction(r)
+        if err != nil {
+            return nil, fmt.Errorf("transaction %d: %s", i, err)
+        }
+        block.Transactions = append(block.Transactions, tx)
+    }
+    if r.Len() != 0 {
+        return nil, fmt.Errorf("%d bytes left after the block", r.Len())
+    }
+    return block, nil
+}
@@ -0,0 +1,50 @@
+package block
+
+import (
+    "bytes"
+    "encoding/hex"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestSerializeGolden(t *testing.T) {
+    coinbase := &transaction.Transaction{
+        Vin:  []transaction.TXInput{{Vout: -1, PubKey: []byte("hi")}},
+        Vout: []transaction.TXOutput{{Value: 10, PubKeyHash: []byte{0x55}}},
+    }
+    header := Header{1, []byte{}, bytes.Repeat([]byte{1}, 32), 1, 0x20010000, 7}
+    b := &Block{Header: header, Transactions: []*transaction.Transaction{coinbase}, Hash: header.Hash(), Height: 300}
+
+    expected := "01000000" + strings.Repeat("00", 32) + strings.Repeat("01", 32) + "0100000000000000" + "00000120" + "0700000000000000" +
+        "ac02" + "01" + hex.EncodeToString(coinbase.Serialize())
+    assert.Equal(t, expected, hex.EncodeToString(b.Serialize()))
+
+    decoded, err := DeserializeBlock(b.Serialize())
+    assert.Nil(t, err)
+    assert.Equal(t, b, decoded, "The genesis parent and the hash come back as they were")
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+    miner := string(wallet.NewWallet().GetAddress())
+    genesis := NewGenesisBlock(transaction.NewCoinbaseTX(miner, "", 0, 0))
+    b := NewBlock([]*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", 1, 0)}, genesis.Hash, 1, genesis.Bits)
+
+    decoded, err := DeserializeBlock(b.Serialize())
+    assert.Nil(t, err)
+    assert.Equal(t, b.Hash, decoded.Hash)
+    assert.Equal(t, b.Serialize(), decoded.Serialize())
+    assert.True(t, NewProofOfWork(decoded).Validate())
+
+    data := b.Serialize()
+    _, err = DeserializeBlock(append(data, 0))
+    assert.NotNil(t, err, "Trailing data is rejected")
+    _, err = DeserializeBlock(data[:len(data)-1])
+    assert.NotNil(t, err, "Truncated data is rejected")
+    _, err = DeserializeBlock(data[:40])
+    assert.NotNil(t, err, "Truncated headers are rejected")
+}
@@ -0,0 +1,52 @@
+package block
+
+import (
+    "fmt"
+    "math/big"
+)
+
+// Consensus parameters. Every node of a network has to use the same values.
+var (
+    // PowLimit is the easiest target a block may have, the genesis block is mined at it
+    PowLimit = new(big.Int).Lsh(big.NewInt(1), 256-8)
+
+    // targetBlockInterval is the number of seconds we aim for between two blocks
+    targetBlockInterval int64 = 10
+
+    // RetargetInterval is the number of blocks after which the difficulty is adjusted
+    RetargetInterval = 10
+
+    // maxRetargetFactor limits how much the difficulty can change in a single adjustment
+    maxRetargetFactor int64 = 4
+
+    // MedianTimeBlocks is the number of blocks whose median timestamp a new block has to be past
+    MedianTimeBlocks = 11
+
+    // MaxFutureBlockTime is how many seconds ahead of our clock a block's timestamp may be
+    MaxFutureBlockTime int64 = 2 * 60 * 60
+)
+
+// Params are the retarget parameters a network may choose
+type Params struct {
+    TargetBlockInterval int64 // seconds we aim for between two blocks
+    RetargetInterval    int   // blocks after which the difficulty is adjusted
+}
+
+// GetParams returns the retarget parameters in use
+func GetParams() Params {
+    return Params{targetBlockInterval, RetargetInterval}
+}
+
+// SetParams changes the retarget parameters. It has to happen at startup, before any block is mined or validated.
+func SetParams(p Params) error {
+    if p.TargetBlockInterval <= 0 {
+            return fmt.Errorf("target block interval must be positive, not %d", p.TargetBlockInterval)
+    }
+    if p.RetargetInterval < 2 {
+            return fmt.Errorf("retarget interval must be at least 2 blocks, not %d", p.RetargetInterval)
+    }
+    targetBlockInterval = p.TargetBlockInterval
+    RetargetInterval = p.RetargetInterval
+
+    return nil
+}
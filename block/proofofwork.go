@@ -0,0 +1,174 @@
+package block
+
+import (
+    "fmt"
+    "crypto/sha256"
+    "math"
+    "math/big"
+    "bytes"
+)
+
+var (
+    maxNonce = math.MaxInt64
+)
+
+//holds a pointer to a block and a pointer to a target
+type ProofOfWork struct {
+    block  *Block
+    target *big.Int
+}
+
+// NewProofOfWork expands the block's bits into the target its hash has to be below
+func NewProofOfWork(b *Block) *ProofOfWork {
+    target := CompactToBig(b.Bits)
+
+    pow := &ProofOfWork{b, target}
+
+    return pow
+}
+
+// prepareData returns the serialized header with the given nonce, only the header is hashed
+func (pow *ProofOfWork) prepareData(nonce int) []byte {
+    header := pow.block.Header
+    header.Nonce = nonce
+
+    return header.Serialize()
+}
+
+// Run performs a proof-of-work
+func (pow *ProofOfWork) Run() (int, []byte) {
+    var hashInt big.Int
+    var hash [32]byte
+    nonce := 0
+
+    for nonce < maxNonce {
+            data := pow.prepareData(nonce)
+            hash = sha256.Sum256(data)
+            if math.Remainder(float64(nonce), 100000) == 0 {
+                    fmt.Printf("\r%x", hash)
+            }
+            hashInt.SetBytes(hash[:])  //Convert the hash to a big integer.
+                    
+            if hashInt.Cmp(pow.target) == -1 {
+                break
+            } else {
+                nonce++
+            }
+        }
+        fmt.Print("\n\n")
+                                                        
+    return nonce, hash[:]
+}
+
+// Work returns the expected number of hashes needed to find a block at this target,
+// that is 2^256 / (target+1). Branches are compared by the sum of their blocks' work.
+func (pow *ProofOfWork) Work() *big.Int {
+    denominator := new(big.Int).Add(pow.target, big.NewInt(1))
+    numerator := new(big.Int).Lsh(big.NewInt(1), 256)
+
+    return numerator.Div(numerator, denominator)
+}
+
+// Validate validates block's PoW and checks that the stored hash is the one it commits to
+func (pow *ProofOfWork) Validate() bool {
+    var hashInt big.Int
+
+    data := pow.prepareData(pow.block.Nonce)
+    hash := sha256.Sum256(data)
+    hashInt.SetBytes(hash[:])
+
+    if pow.target.Sign() <= 0 || pow.target.Cmp(PowLimit) > 0 {
+            return false
+    }
+    isValid := hashInt.Cmp(pow.target) == -1 && bytes.Compare(hash[:], pow.block.Hash) == 0
+
+    return isValid
+}
+
+// CompactToBig expands bits in the compact format used by Bitcoin into a target.
+// The highest byte is the length of the number in bytes, the lower 23 bits are
+// its most significant bytes and bit 23 is the sign.
+func CompactToBig(compact uint32) *big.Int {
+    mantissa := int64(compact & 0x007fffff)
+    exponent := uint(compact >> 24)
+
+    var target *big.Int
+    if exponent <= 3 {
+            target = big.NewInt(mantissa >> (8 * (3 - exponent)))
+    } else {
+            target = big.NewInt(mantissa)
+            target.Lsh(target, 8*(exponent-3))
+    }
+    if compact&0x00800000 != 0 {
+            target.Neg(target)
+    }
+
+    return target
+}
+
+// BigToCompact converts a target into the compact format, dropping all but its 3 most significant bytes
+func BigToCompact(target *big.Int) uint32 {
+    if target.Sign() == 0 {
+            return 0
+    }
+
+    var mantissa uint32
+    exponent := uint(len(target.Bytes()))
+    abs := new(big.Int).Abs(target)
+    if exponent <= 3 {
+            mantissa = uint32(abs.Int64()) << (8 * (3 - exponent))
+    } else {
+            mantissa = uint32(abs.Rsh(abs, 8*(exponent-3)).Int64())
+    }
+    // the mantissa would be read as negative, so move it down a byte
+    if mantissa&0x00800000 != 0 {
+            mantissa >>= 8
+            exponent++
+    }
+
+    compact := uint32(exponent<<24) | mantissa
+    if target.Sign() < 0 {
+            compact |= 0x00800000
+    }
+
+    return compact
+}
+
+// CalcNextBits computes the bits for the first block of a retarget period.
+// The previous target is scaled by how long the last period actually took
+// compared to targetBlockInterval, limited to maxRetargetFactor either way.
+func CalcNextBits(lastBits uint32, actualTimespan int64) uint32 {
+    expectedTimespan := targetBlockInterval * int64(RetargetInterval-1)
+
+    if actualTimespan < expectedTimespan/maxRetargetFactor {
+            actualTimespan = expectedTimespan / maxRetargetFactor
+    }
+    if actualTimespan > expectedTimespan*maxRetargetFactor {
+            actualTimespan = expectedTimespan * maxRetargetFactor
+    }
+
+    target := CompactToBig(lastBits)
+    target.Mul(target, big.NewInt(actualTimespan))
+    target.Div(target, big.NewInt(expectedTimespan))
+    if target.Cmp(PowLimit) > 0 {
+            target.Set(PowLimit)
+    }
+
+    return BigToCompact(target)
+}
+
+// EasiestTarget returns the easiest target a block may have retargets retarget periods
+// away from a block with bits, before or after it. A retarget changes the target by at
+// most maxRetargetFactor, a bit more going back because of the rounding in CalcNextBits,
+// so every period may make it maxRetargetFactor+1 times easier here.
+func EasiestTarget(bits uint32, retargets int) *big.Int {
+    target := CompactToBig(bits)
+    for i := 0; i < retargets && target.Cmp(PowLimit) < 0; i++ {
+            target.Mul(target, big.NewInt(maxRetargetFactor+1))
+    }
+    if target.Cmp(PowLimit) > 0 {
+            target.Set(PowLimit)
+    }
+
+    return target
+}
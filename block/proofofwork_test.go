@@ -0,0 +1,55 @@
+package block
+
+import (
+    "math/big"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestCompact(t *testing.T) {
+    bitcoinGenesis, _ := new(big.Int).SetString("00000000ffff0000000000000000000000000000000000000000000000000000", 16)
+
+    assert.Equal(t, bitcoinGenesis, CompactToBig(0x1d00ffff), "Bitcoin genesis bits expand")
+    assert.Equal(t, uint32(0x1d00ffff), BigToCompact(bitcoinGenesis), "Bitcoin genesis target compacts")
+    assert.Equal(t, uint32(0x20010000), BigToCompact(PowLimit), "PoW limit compacts")
+    assert.Equal(t, PowLimit, CompactToBig(BigToCompact(PowLimit)), "PoW limit round trips")
+
+    // 0x80 would set the sign bit, so it moves to the next byte
+    assert.Equal(t, uint32(0x02008000), BigToCompact(big.NewInt(0x80)))
+    assert.Equal(t, big.NewInt(0x80), CompactToBig(0x02008000))
+    assert.Equal(t, -1, CompactToBig(0x01810000).Sign(), "Sign bit makes the target negative")
+}
+
+func TestCalcNextBits(t *testing.T) {
+    bits := uint32(0x1f00ffff)
+    target := CompactToBig(bits)
+    expectedTimespan := targetBlockInterval * int64(RetargetInterval-1)
+
+    assert.Equal(t, bits, CalcNextBits(bits, expectedTimespan), "On schedule keeps the difficulty")
+
+    half := new(big.Int).Div(target, big.NewInt(2))
+    assert.Equal(t, BigToCompact(half), CalcNextBits(bits, expectedTimespan/2), "Twice as fast halves the target")
+
+    fastest := expectedTimespan / maxRetargetFactor
+    assert.Equal(t, CalcNextBits(bits, fastest), CalcNextBits(bits, 0), "Change is clamped when blocks come too fast")
+    assert.True(t, CompactToBig(CalcNextBits(bits, 0)).Cmp(new(big.Int).Div(target, big.NewInt(maxRetargetFactor+1))) > 0)
+
+    quadruple := new(big.Int).Mul(target, big.NewInt(maxRetargetFactor))
+    assert.Equal(t, BigToCompact(quadruple), CalcNextBits(bits, expectedTimespan*100), "Change is clamped when blocks come too slow")
+
+    assert.Equal(t, BigToCompact(PowLimit), CalcNextBits(BigToCompact(PowLimit), expectedTimespan*2), "Target never exceeds the PoW limit")
+}
+
+func TestSetParams(t *testing.T) {
+    defer SetParams(GetParams())
+
+    assert.NotNil(t, SetParams(Params{0, 10}), "Blocks can't come instantly")
+    assert.NotNil(t, SetParams(Params{10, 1}), "A retarget needs a timespan")
+    assert.Equal(t, Params{10, 10}, GetParams(), "Bad parameters change nothing")
+
+    assert.Nil(t, SetParams(Params{600, 2016}))
+    bits := uint32(0x1f00ffff)
+    assert.Equal(t, bits, CalcNextBits(bits, 600*2015), "Retargeting follows the parameters")
+    assert.NotEqual(t, bits, CalcNextBits(bits, 10*9))
+}
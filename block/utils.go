@@ -1,4 +1,4 @@
-package main
+package block
 
 import (
     "bytes"
@@ -16,10 +16,3 @@ func IntToHex(num int64) []byte {
         
     return buff.Bytes()
 }
-
-
-func ReverseBytes(s []byte) {
-    for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-            s[i], s[j] = s[j], s[i]
-    }
-}
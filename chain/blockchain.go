@@ -0,0 +1,850 @@
+package chain
+
+import (
+        "bytes"
+        "errors"
+        "fmt"
+        "encoding/gob"
+        "encoding/hex"
+        "log"
+        "crypto/ecdsa"
+        "math/big"
+        "os"
+        "sync"
+        "time"
+        "github.com/boltdb/bolt"
+
+        "blockchain_go/block"
+        "blockchain_go/transaction"
+        "blockchain_go/utxo"
+    )
+
+// DBFile is the name of the Blockchain DB of a node, formatted with its node ID
+const DBFile = "blockchain_%s.db"
+const blocksBucket = "blocks"
+const chainworkBucket = "chainwork"  // block hash -> cumulative work of the branch ending at that block
+const orphansBucket = "orphans"      // where older databases stored blocks waiting for their parent, see dropStoredOrphans
+const genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
+
+// Errors returned when opening the blockchain and looking things up in it
+var (
+    ErrBlockchainExists    = errors.New("Blockchain already exists.")
+    ErrNoBlockchain        = errors.New("No existing blockchain found. Create one first.")
+    ErrBlockNotFound       = errors.New("Block is not found.")
+    ErrTransactionNotFound = errors.New("Transaction is not found")
+    ErrLegacyBlockchain    = errors.New("The blockchain was written by an older version. Delete it and resync from the network.")
+)
+
+
+// Blockchain keeps a sequence of Blocks
+type Blockchain struct {
+    mu       sync.RWMutex // guards tip, which node goroutines read while blocks are added, and onNewTip
+    tip      []byte
+    onNewTip []func(*block.Block)
+    orphans  *orphanPool
+    db       *bolt.DB
+}
+
+// Tip returns the hash of the last block of the main chain
+func (bc *Blockchain) Tip() []byte {
+    bc.mu.RLock()
+    defer bc.mu.RUnlock()
+
+    return bc.tip
+}
+
+func (bc *Blockchain) setTip(hash []byte) {
+    bc.mu.Lock()
+    bc.tip = hash
+    bc.mu.Unlock()
+}
+
+// OnNewTip registers f to be called with the new tip whenever the main chain changes,
+// once the change is committed. f runs on the goroutine adding the block, so it shouldn't block.
+func (bc *Blockchain) OnNewTip(f func(*block.Block)) {
+    bc.mu.Lock()
+    defer bc.mu.Unlock()
+
+    bc.onNewTip = append(bc.onNewTip, f)
+}
+
+// DB returns the DB the blockchain is stored in, which the UTXO set and the indexes share
+func (bc *Blockchain) DB() *bolt.DB {
+    return bc.db
+}
+
+// Close closes the blockchain DB
+func (bc *Blockchain) Close() error {
+    return bc.db.Close()
+}
+
+// CreateBlockchain createss a new Blockchain DB for the node, with a genesis block paying to address
+func CreateBlockchain(address, nodeID string) (*Blockchain, error) {
+    return CreateBlockchainFile(fmt.Sprintf(DBFile, nodeID), address)
+}
+
+// CreateBlockchainFile is CreateBlockchain with the path of the DB rather than a node ID
+func CreateBlockchainFile(dbFile, address string) (*Blockchain, error) {
+    if dbExists(dbFile) {   
+            return nil, ErrBlockchainExists
+    }
+    var tip []byte
+    cbtx := transaction.NewCoinbaseTX(address, genesisCoinbaseData, 0, 0)  // takes an address which will receive the reward for mining the genesis block.
+    genesis := block.NewGenesisBlock(cbtx)
+    db, err := bolt.Open(dbFile, 0600, nil)
+    if err != nil {
+        return nil, err
+    }
+    err = db.Update(func(tx *bolt.Tx) error {  // open a read-write transaction
+        b, err := tx.CreateBucket([]byte(blocksBucket))  //create the bucket
+        if err != nil {
+                return err
+        }
+        err = b.Put(genesis.Hash, genesis.Serialize())
+        if err != nil {
+                return err
+        }
+
+        err = b.Put([]byte("l"), genesis.Hash)  //update the l key storing the last block hash of the chain. ??
+        if err != nil {
+                return err
+        }
+        err = putEncoding(tx)
+        if err != nil {
+                return err
+        }
+        for _, name := range []string{chainworkBucket, txIndexBucket, heightsBucket} {
+                _, err = tx.CreateBucket([]byte(name))
+                if err != nil {
+                        return err
+                }
+        }
+        err = utxo.CreateBuckets(tx)
+        if err != nil {
+                return err
+        }
+        putChainWork(tx, genesis)
+        connectBlock(tx, genesis)
+        tip = genesis.Hash
+
+        return nil
+    })
+    if err != nil {
+            db.Close()
+            os.Remove(dbFile) // nothing was written, a later attempt can start over
+            return nil, err
+    }
+
+    bc := Blockchain{tip: tip, orphans: newOrphanPool(maxOrphans, orphanExpiry), db: db} //only the tip of the chain is stored. Also, we store a DB connection, all block stored in DB
+
+    return &bc, nil
+}
+
+// NewBlockchain opens the Blockchain DB of the node, which must have been created first
+func NewBlockchain(nodeID string) (*Blockchain, error) {
+    return NewBlockchainFile(fmt.Sprintf(DBFile, nodeID))
+}
+
+// NewBlockchainFile is NewBlockchain with the path of the DB rather than a node ID
+func NewBlockchainFile(dbFile string) (*Blockchain, error) {
+    if dbExists(dbFile) == false {  // fix bug
+            return nil, ErrNoBlockchain
+    }
+    var tip []byte
+    reindex := false
+    db, err := bolt.Open(dbFile, 0600, nil)
+    if err != nil {
+        return nil, err
+    }
+    err = db.Update(func(tx *bolt.Tx) error {  // open a read-write transaction
+            b := tx.Bucket([]byte(blocksBucket))  // obtain the bucket storing our blocks
+            if b == nil {
+                    return ErrNoBlockchain
+            }
+            tip = append([]byte{}, b.Get([]byte("l"))...)  // values returned by bolt are only valid inside the transaction
+
+            err := checkEncoding(tx)
+            if err != nil {
+                    return err
+            }
+            reindex, err = utxo.Migrate(tx)
+            if err != nil {
+                    return err
+            }
+
+            // databases created before fork handling have no block index yet
+            cw, err := tx.CreateBucketIfNotExists([]byte(chainworkBucket))
+            if err != nil {
+                    return err
+            }
+            err = dropStoredOrphans(tx)
+            if err != nil {
+                    return err
+            }
+            if cw.Get(tip) == nil {
+                    indexMainChain(tx, tip)
+            }
+            if tx.Bucket([]byte(txIndexBucket)) == nil {
+                    indexMainChainTransactions(tx, tip)
+            }
+            if tx.Bucket([]byte(heightsBucket)) == nil {
+                    indexMainChainHeights(tx, tip)
+            }
+            return nil
+        })
+    if err != nil {
+            db.Close()
+            return nil, err
+    }
+
+    bc := Blockchain{tip: tip, orphans: newOrphanPool(maxOrphans, orphanExpiry), db: db} //only the tip of the chain is stored. Also, we store a DB connection, all block stored in DB
+    if reindex {
+            fmt.Println("Rebuilding the UTXO set...")
+            utxo.UTXOSet{Blockchain: &bc}.Reindex()
+    }
+
+    return &bc, nil
+}
+
+// AddBlock validates the block and saves it into the blockchain.
+// Blocks on side branches are stored and indexed as well, blocks whose parent isn't
+// known yet wait in memory until it shows up. Whenever a branch has
+// more cumulative work than the main chain, the chain is reorganized onto it:
+// the old branch is disconnected from the UTXO set and the new one connected,
+// all in the same DB transaction as the block write.
+// It returns the blocks connected to and disconnected from the main chain.
+// A block breaking the consensus rules is rejected with a *BlockError, and nothing is written.
+func (bc *Blockchain) AddBlock(blk *block.Block) ([]*block.Block, []*block.Block, error) {
+    var connected, disconnected []*block.Block
+
+    err := checkBlock(blk)
+    if err != nil {
+            return nil, nil, err
+    }
+
+    err = bc.db.Update(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(blocksBucket))
+            blockInDb := b.Get(blk.Hash)
+        
+            if blockInDb != nil {
+                    return nil
+            }
+
+            // we can't tell how much work is behind a block until its parent shows up
+            if len(blk.PrevBlockHash) != 0 && getChainWork(tx, blk.PrevBlockHash) == nil {
+                    err := checkOrphan(tx, blk)
+                    if err != nil {
+                            return err
+                    }
+                    bc.orphans.add(blk)
+                    return nil
+            }
+            err := checkBlockContext(tx, blk)
+            if err != nil {
+                    return err
+            }
+
+            best, bestWork, err := bc.indexBlock(tx, blk)
+            if err != nil {
+                    return err
+            }
+            if bestWork.Cmp(getChainWork(tx, b.Get([]byte("l")))) > 0 { // the tip in memory only moves once this commits
+                    connected, disconnected, err = bc.reorganize(tx, best)
+            }
+        
+            return err
+    })
+    if err != nil {
+            return nil, nil, err
+    }
+    if len(connected) > 0 {
+            tip := connected[len(connected)-1]
+            bc.setTip(tip.Hash) // only once the new main chain is committed
+
+            bc.mu.RLock()
+            listeners := bc.onNewTip
+            bc.mu.RUnlock()
+            for _, f := range listeners {
+                    f(tip)
+            }
+    }
+
+    return connected, disconnected, nil
+}
+
+// reorganize makes newTip the tip of the main chain.
+// Blocks from the current tip back to the fork point are disconnected from
+// the UTXO set, then the blocks of the new branch are validated and connected
+// in order. If any of them is invalid the whole DB transaction is rolled back.
+func (bc *Blockchain) reorganize(tx *bolt.Tx, newTip *block.Block) ([]*block.Block, []*block.Block, error) {
+    var detach, attach []*block.Block
+
+    oldBlock := getBlockTx(tx, tx.Bucket([]byte(blocksBucket)).Get([]byte("l")))
+    newBlock := newTip
+
+    for oldBlock.Height > newBlock.Height {
+            detach = append(detach, oldBlock)
+            oldBlock = getBlockTx(tx, oldBlock.PrevBlockHash)
+    }
+    for newBlock.Height > oldBlock.Height {
+            attach = append(attach, newBlock)
+            newBlock = getBlockTx(tx, newBlock.PrevBlockHash)
+    }
+    for bytes.Compare(oldBlock.Hash, newBlock.Hash) != 0 {
+            if len(oldBlock.PrevBlockHash) == 0 || len(newBlock.PrevBlockHash) == 0 {
+                    return nil, nil, rejectBlock(newTip, RejectUnknownParent, "its branch has no common ancestor with the main chain")
+            }
+            detach = append(detach, oldBlock)
+            attach = append(attach, newBlock)
+            oldBlock = getBlockTx(tx, oldBlock.PrevBlockHash)
+            newBlock = getBlockTx(tx, newBlock.PrevBlockHash)
+    }
+    if len(detach) > 0 {
+            log.Printf("Reorganizing: %d blocks disconnected, %d blocks connected, fork at %x", len(detach), len(attach), oldBlock.Hash)
+    }
+
+    for _, b := range detach {
+            disconnectBlock(tx, b)
+    }
+
+    var connected []*block.Block
+    for i := len(attach) - 1; i >= 0; i-- {
+            err := checkTransactions(tx, attach[i])
+            if err != nil {
+                    return nil, nil, err
+            }
+            connectBlock(tx, attach[i])
+            connected = append(connected, attach[i])
+    }
+
+    err := tx.Bucket([]byte(blocksBucket)).Put([]byte("l"), newTip.Hash)
+    if err != nil {
+            return nil, nil, err
+    }
+
+    return connected, detach, nil
+}
+
+// FindTransaction finds a main chain transaction by ID with the transaction index
+func (bc *Blockchain) FindTransaction(ID []byte) (transaction.Transaction, error) {
+    tx, _, err := bc.GetTransaction(ID)
+    if err != nil {
+            return transaction.Transaction{}, err
+    }
+
+    return *tx, nil
+}
+
+// FindUTXO finds and returns all unspent transaction outputs, by their chainstate key
+func (bc *Blockchain) FindUTXO() map[string]utxo.UTXOEntry {
+    UTXO := make(map[string]utxo.UTXOEntry)
+    spentTXOs := make(map[string][]int)
+    bci := bc.Iterator().WithBatch(100)
+    for {
+        block, err := bci.Next()
+        if err == ErrEndOfChain {
+                break
+        }
+        if err != nil {
+                log.Panic(err)
+        }
+    
+        for _, tx := range block.Transactions {
+            txID := hex.EncodeToString(tx.ID)
+                    
+            Outputs:
+                    for outIdx, out := range tx.Vout {
+                    // Was the output spent?
+                        if spentTXOs[txID] != nil {
+                            for _, spentOutIdx := range spentTXOs[txID] {
+                                    if spentOutIdx == outIdx {
+                                            continue Outputs
+                                    }
+                            }
+                        }
+                        UTXO[string(utxo.Key(tx.ID, outIdx))] = utxo.UTXOEntry{TXOutput: out, Height: block.Height, Coinbase: tx.IsCoinbase()}
+                    }
+                    if tx.IsCoinbase() == false {
+                            for _, in := range tx.Vin {
+                                    inTxID := hex.EncodeToString(in.Txid)
+                                    spentTXOs[inTxID] = append(spentTXOs[inTxID], in.Vout)
+                            }
+                    }
+        }
+    }
+    return UTXO
+}
+
+// GetBestHeight returns the height of the latest block
+func (bc *Blockchain) GetBestHeight() int {
+    var lastBlock block.Block
+
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(blocksBucket))
+            lastHash := b.Get([]byte("l"))
+            lastBlock = *getBlockTx(tx, lastHash)
+        
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return lastBlock.Height
+}
+
+// GetBlock finds a block by its hash and returns it
+func (bc *Blockchain) GetBlock(blockHash []byte) (block.Block, error) {
+    var blk block.Block
+
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(blocksBucket))
+        
+            blockData := b.Get(blockHash)
+        
+            if blockData == nil {
+                    return ErrBlockNotFound
+            }
+        
+            found, err := decodeBlock(blockHash, blockData)
+            if err != nil {
+                    return err
+            }
+            blk = *found
+    
+            return nil
+    })
+    if err != nil {
+            return blk, err
+    }
+
+    return blk, nil
+}
+
+
+// HasBlock tells whether the block is stored, on the main chain, a side branch or waiting for its parent
+func (bc *Blockchain) HasBlock(blockHash []byte) bool {
+    if bc.orphans.has(blockHash) {
+            return true
+    }
+
+    found := false
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            found = tx.Bucket([]byte(blocksBucket)).Get(blockHash) != nil
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return found
+}
+
+// GetChainWork returns the cumulative work of the branch ending at the block, nil if it isn't connected to the chain
+func (bc *Blockchain) GetChainWork(blockHash []byte) *big.Int {
+    var work *big.Int
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            work = getChainWork(tx, blockHash)
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return work
+}
+
+// MainChainAfter finds the most recent locator hash that is on the main chain and
+// returns the hashes of up to max main chain blocks following it, oldest first.
+// The list ends early at stop. If no locator hash is known it starts from genesis.
+// The hashes are read forward from the height index, the cost doesn't grow with the chain.
+func (bc *Blockchain) MainChainAfter(locator [][]byte, stop []byte, max int) [][]byte {
+    var hashes [][]byte
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            heights := tx.Bucket([]byte(heightsBucket))
+            from := 0
+            for _, hash := range locator {
+                    if tx.Bucket([]byte(blocksBucket)).Get(hash) == nil {
+                            continue
+                    }
+                    if height := getBlockTx(tx, hash).Height; bytes.Equal(heights.Get(heightKey(height)), hash) {
+                            from = height + 1
+                            break
+                    }
+            }
+
+            c := heights.Cursor()
+            for k, hash := c.Seek(heightKey(from)); k != nil && len(hashes) < max; k, hash = c.Next() {
+                    hashes = append(hashes, append([]byte{}, hash...))
+                    if bytes.Equal(hash, stop) {
+                            break
+                    }
+            }
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return hashes
+}
+
+// BlockLocator describes where our main chain is, for a peer to find the last block we have in common
+func (bc *Blockchain) BlockLocator() [][]byte {
+    var locator [][]byte
+
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            locator = Locator(getTipTx(tx), func(hash []byte) []byte {
+                    return getBlockTx(tx, hash).PrevBlockHash
+            })
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return locator
+}
+
+
+// MineBlock mines a block with the provided transactions on top of the tip.
+// It's added like any received block, so the UTXO set is updated in the same DB transaction.
+// The transactions' signatures are checked first, the rest of the rules when the block is added.
+// coinbase builds the block's coinbase for the height it gets on top of the tip it's mined on,
+// and is put first. With a nil coinbase the transactions must include one already.
+// Like AddBlock it returns the blocks connected to and disconnected from the main chain,
+// which don't include the new block if another one took the tip in the meantime.
+func (bc *Blockchain) MineBlock(txs []*transaction.Transaction, coinbase func(height int) *transaction.Transaction) (*block.Block, []*block.Block, []*block.Block, error) {
+    var lastHash []byte
+    var lastHeight int
+    var bits uint32
+    timestamp := time.Now().Unix()
+
+    for _, tx := range txs {
+            err := bc.VerifyTransaction(tx)
+            if err != nil {
+                    return nil, nil, nil, err
+            }
+    }
+
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(blocksBucket))  //obtain the bucket storing our blocks
+            lastHash = append([]byte{}, b.Get([]byte("l"))...)
+
+            block := getBlockTx(tx, lastHash)
+            lastHeight = block.Height
+            bits = nextBits(tx, block)
+            if median := medianTimePast(tx, block); timestamp <= median { // blocks mined in the same second
+                    timestamp = median + 1
+            }
+
+            return nil
+    })
+    if err != nil {
+            return nil, nil, nil, err
+    }
+    if coinbase != nil {
+            txs = append([]*transaction.Transaction{coinbase(lastHeight + 1)}, txs...)
+    }
+//After mining a new block, we save it into the DB and it becomes the new tip,
+//unless another block arrived in the meantime.
+    newBlock := block.NewBlockAt(txs, lastHash, lastHeight+1, bits, timestamp)
+    
+    connected, disconnected, err := bc.AddBlock(newBlock)
+    if err != nil {
+            return nil, nil, nil, err
+    }
+
+    return newBlock, connected, disconnected, nil
+}
+
+// prevTransactions finds the main chain transactions whose outputs tx spends
+func (bc *Blockchain) prevTransactions(tx *transaction.Transaction) (map[string]transaction.Transaction, error) {
+    prevTXs := make(map[string]transaction.Transaction)
+
+    for _, vin := range tx.Vin {
+            prevTX, err := bc.FindTransaction(vin.Txid)
+            if err != nil {
+                    return nil, fmt.Errorf("%w: %x", transaction.ErrMissingPrevTx, vin.Txid)
+            }
+            prevTXs[hex.EncodeToString(prevTX.ID)] = prevTX
+    }
+
+    return prevTXs, nil
+}
+
+// SignTransaction signs inputs of a Transaction
+// SignTransaction takes a transaction, finds transactions it references, and signs it;
+func (bc *Blockchain) SignTransaction(tx *transaction.Transaction, privKey ecdsa.PrivateKey) error {
+    prevTXs, err := bc.prevTransactions(tx)
+    if err != nil {
+            return err
+    }
+                                
+    return tx.Sign(privKey, prevTXs)
+}
+
+// VerifyTransaction verifies transaction input signatures, nil meaning they are all valid
+func (bc *Blockchain) VerifyTransaction(tx *transaction.Transaction) error {
+    if tx.IsCoinbase() {  // another bug fix
+        return nil
+    }
+    prevTXs, err := bc.prevTransactions(tx)
+    if err != nil {
+            return err
+    }
+                                
+    return tx.Verify(prevTXs)
+}
+
+// getBlockTx reads a block by its hash within an open DB transaction.
+// Callers only ask for blocks the DB refers to, a missing or unreadable one means it is corrupted.
+func getBlockTx(tx *bolt.Tx, hash []byte) *block.Block {
+    block, err := readBlockTx(tx, hash)
+    if err != nil {
+            log.Panicf("ERROR: %s", err)
+    }
+
+    return block
+}
+
+// readBlockTx is getBlockTx returning an error rather than panicking
+func readBlockTx(tx *bolt.Tx, hash []byte) (*block.Block, error) {
+    blockData := tx.Bucket([]byte(blocksBucket)).Get(hash)
+    if blockData == nil {
+            return nil, fmt.Errorf("Block %x is not found", hash)
+    }
+    block, err := decodeBlock(hash, blockData)
+    if err != nil {
+            return nil, fmt.Errorf("Block %x: %s", hash, err)
+    }
+
+    return block, nil
+}
+
+// getTipTx returns the hash of the main chain tip as seen by the DB transaction.
+// Read it rather than bc.Tip() inside a transaction: the tip in memory moves on once
+// a block is committed, and may name a block a transaction opened before can't see.
+func getTipTx(tx *bolt.Tx) []byte {
+    return tx.Bucket([]byte(blocksBucket)).Get([]byte("l"))
+}
+
+// connectBlock updates the UTXO set and the indexes for a block becoming the tip of the main chain
+func connectBlock(tx *bolt.Tx, b *block.Block) {
+    utxo.ConnectBlock(tx, b)
+    indexTransactions(tx, b)
+    indexHeight(tx, b)
+}
+
+// disconnectBlock reverts connectBlock for the tip of the main chain
+func disconnectBlock(tx *bolt.Tx, b *block.Block) {
+    utxo.DisconnectBlock(tx, b, func(from, txid []byte) (*block.Block, *transaction.Transaction) {
+            return findTransactionBlockTx(tx, from, txid)
+    })
+    unindexTransactions(tx, b)
+    unindexHeight(tx, b)
+}
+
+// findTransactionTx looks for a transaction in the block with the given hash and its ancestors
+func findTransactionTx(tx *bolt.Tx, blockHash, ID []byte) (transaction.Transaction, error) {
+    _, t := findTransactionBlockTx(tx, blockHash, ID)
+    if t == nil {
+            return transaction.Transaction{}, ErrTransactionNotFound
+    }
+
+    return *t, nil
+}
+
+// findTransactionBlockTx is findTransactionTx that also returns the block the transaction is in, both nil if it isn't found.
+// Besides the block itself, only main chain blocks are searched, so they must be its ancestors:
+// the block is the tip or it is being connected on top of it.
+func findTransactionBlockTx(tx *bolt.Tx, blockHash, ID []byte) (*block.Block, *transaction.Transaction) {
+    block := getBlockTx(tx, blockHash)
+    for _, t := range block.Transactions {
+            if bytes.Compare(t.ID, ID) == 0 {
+                    return block, t
+            }
+    }
+
+    return lookupTransactionTx(tx, ID)
+}
+
+// nextBits returns the difficulty bits a block built on parent must have.
+// They stay the same within a retarget period and are recomputed from the
+// timestamps of the previous period at every RetargetInterval blocks.
+func nextBits(tx *bolt.Tx, parent *block.Block) uint32 {
+    if (parent.Height+1)%block.RetargetInterval != 0 {
+            return parent.Bits
+    }
+
+    first := parent
+    for i := 0; i < block.RetargetInterval-1; i++ {
+            first = getBlockTx(tx, first.PrevBlockHash)
+    }
+
+    return block.CalcNextBits(parent.Bits, parent.Timestamp-first.Timestamp)
+}
+
+// medianTimePast returns the median timestamp of the block and the ones before it,
+// block.MedianTimeBlocks of them or as many as there are. Its child has to be past it.
+func medianTimePast(tx *bolt.Tx, blk *block.Block) int64 {
+    var timestamps []int64
+    for i := 0; i < block.MedianTimeBlocks; i++ {
+            timestamps = append(timestamps, blk.Timestamp)
+            if len(blk.PrevBlockHash) == 0 {
+                    break
+            }
+            blk = getBlockTx(tx, blk.PrevBlockHash)
+    }
+
+    return block.MedianTime(timestamps)
+}
+
+// getChainWork returns the cumulative work of the branch ending at the block, or nil if the block is not indexed
+func getChainWork(tx *bolt.Tx, hash []byte) *big.Int {
+    work := tx.Bucket([]byte(chainworkBucket)).Get(hash)
+    if work == nil {
+            return nil
+    }
+
+    return new(big.Int).SetBytes(work)
+}
+
+// putChainWork indexes a block whose parent is already indexed and returns its cumulative work
+func putChainWork(tx *bolt.Tx, blk *block.Block) *big.Int {
+    work := block.NewProofOfWork(blk).Work()
+    if len(blk.PrevBlockHash) != 0 {
+            work.Add(work, getChainWork(tx, blk.PrevBlockHash))
+    }
+
+    err := tx.Bucket([]byte(chainworkBucket)).Put(blk.Hash, work.Bytes())
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return work
+}
+
+// indexBlock stores and indexes a block along with any orphans that were waiting for it.
+// Orphans that turn out not to fit their parent are dropped with their descendants.
+// It returns the block with the most cumulative work among those indexed.
+func (bc *Blockchain) indexBlock(tx *bolt.Tx, blk *block.Block) (*block.Block, *big.Int, error) {
+    var best *block.Block
+    var bestWork *big.Int
+
+    first := blk // already checked by the caller
+    queue := []*block.Block{blk}
+    for len(queue) > 0 {
+            blk := queue[0]
+            queue = queue[1:]
+
+            if blk != first {
+                    if err := checkBlockContext(tx, blk); err != nil {
+                            log.Printf("Dropping orphan %x and the blocks built on it: %s", blk.Hash, err)
+                            bc.orphans.drop(blk.Hash)
+                            continue
+                    }
+            }
+            // checkBlock made sure of it, decodeBlock relies on it
+            if !bytes.Equal(blk.Hash, blk.Header.Hash()) {
+                    return nil, nil, fmt.Errorf("block %x hashes to %x", blk.Hash, blk.Header.Hash())
+            }
+            err := tx.Bucket([]byte(blocksBucket)).Put(blk.Hash, blk.Serialize())
+            if err != nil {
+                    return nil, nil, err
+            }
+            work := putChainWork(tx, blk)
+            if best == nil || work.Cmp(bestWork) > 0 {
+                    best = blk
+                    bestWork = work
+            }
+            queue = append(queue, bc.orphans.take(blk.Hash)...)
+    }
+
+    return best, bestWork, nil
+}
+
+// Locator lists hashes going back from hash, one by one for the first ten,
+// then doubling the step every time, and always ending with the genesis block.
+// From these few hashes a peer finds the last block it has in common with us.
+func Locator(hash []byte, parent func([]byte) []byte) [][]byte {
+    var locator [][]byte
+    step := 1
+
+    for len(hash) != 0 {
+            locator = append(locator, hash)
+            if len(locator) > 10 {
+                    step *= 2
+            }
+
+            last := hash
+            for i := 0; i < step && len(hash) != 0; i++ {
+                    last = hash
+                    hash = parent(hash)
+            }
+            if len(hash) == 0 && !bytes.Equal(last, locator[len(locator)-1]) {
+                    locator = append(locator, last) // the genesis block
+            }
+    }
+
+    return locator
+}
+
+// indexMainChain indexes the chain ending at tip, used for databases without a block index
+func indexMainChain(tx *bolt.Tx, tip []byte) {
+    var blocks []*block.Block
+
+    for hash := tip; len(hash) != 0; {
+            block := getBlockTx(tx, hash)
+            blocks = append(blocks, block)
+            hash = block.PrevBlockHash
+    }
+    for i := len(blocks) - 1; i >= 0; i-- {
+            putChainWork(tx, blocks[i])
+    }
+}
+
+// dropStoredOrphans deletes the blocks older databases stored while they waited for
+// their parent, along with the bucket listing them. Orphans are kept in memory now.
+func dropStoredOrphans(tx *bolt.Tx) error {
+    orphans := tx.Bucket([]byte(orphansBucket))
+    if orphans == nil {
+            return nil
+    }
+
+    blocks := tx.Bucket([]byte(blocksBucket))
+    err := orphans.ForEach(func(parent, data []byte) error {
+            for _, hash := range gobDecodeHashes(data) {
+                    err := blocks.Delete(hash)
+                    if err != nil {
+                            return err
+                    }
+            }
+            return nil
+    })
+    if err != nil {
+            return err
+    }
+
+    return tx.DeleteBucket([]byte(orphansBucket))
+}
+
+func gobDecodeHashes(data []byte) [][]byte {
+    var hashes [][]byte
+
+    dec := gob.NewDecoder(bytes.NewReader(data))
+    err := dec.Decode(&hashes)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return hashes
+}
+
+func dbExists(dbFile string) bool {
+    if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+            return false
+    }
+        
+    return true
+}
@@ -0,0 +1,107 @@
+package chain
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+)
+
+// ErrEndOfChain is returned by Next once the iterator is past the last block
+var ErrEndOfChain = errors.New("end of chain")
+
+// BlockchainIterator walks the chain from a block, backwards following the previous
+// block hashes or forwards along the main chain with the height index.
+type BlockchainIterator struct {
+    currentHash []byte   // next block to read, nil at the end
+    db          *bolt.DB
+    forward     bool
+    limit       int      // blocks left to return, negative for no limit
+    batch       int      // blocks read per DB transaction
+    buffered    []*block.Block // read but not returned yet
+}
+
+// Iterator walks back from the tip to genesis
+func (bc *Blockchain) Iterator() *BlockchainIterator {
+    return bc.IteratorFrom(bc.Tip(), false)
+}
+
+// IteratorFrom starts at the block with the given hash. Going forward the block must be on the main chain.
+func (bc *Blockchain) IteratorFrom(hash []byte, forward bool) *BlockchainIterator {
+    return &BlockchainIterator{currentHash: hash, db: bc.db, forward: forward, limit: -1, batch: 1}
+}
+
+// IteratorFromHeight starts at the main chain block at the height
+func (bc *Blockchain) IteratorFromHeight(height int, forward bool) (*BlockchainIterator, error) {
+    block, err := bc.GetBlockByHeight(height)
+    if err != nil {
+            return nil, err
+    }
+
+    return bc.IteratorFrom(block.Hash, forward), nil
+}
+
+// WithLimit stops the iterator after n blocks
+func (i *BlockchainIterator) WithLimit(n int) *BlockchainIterator {
+    i.limit = n
+    return i
+}
+
+// WithBatch reads n blocks at a time in a single DB transaction, for long walks
+func (i *BlockchainIterator) WithBatch(n int) *BlockchainIterator {
+    if n < 1 {
+            n = 1
+    }
+    i.batch = n
+    return i
+}
+
+// Next returns the next block, or ErrEndOfChain after genesis, the tip going forward, or the limit
+func (i *BlockchainIterator) Next() (*block.Block, error) {
+    if len(i.buffered) == 0 {
+            err := i.db.View(i.read)
+            if err != nil {
+                    return nil, err
+            }
+    }
+    if len(i.buffered) == 0 {
+            return nil, ErrEndOfChain
+    }
+
+    block := i.buffered[0]
+    i.buffered = i.buffered[1:]
+    return block, nil
+}
+
+// read buffers the next blocks, up to the batch size
+func (i *BlockchainIterator) read(tx *bolt.Tx) error {
+    blocks := tx.Bucket([]byte(blocksBucket))
+    heights := tx.Bucket([]byte(heightsBucket))
+
+    for len(i.buffered) < i.batch && i.limit != 0 && len(i.currentHash) != 0 {
+            blockData := blocks.Get(i.currentHash)
+            if blockData == nil {
+                    return fmt.Errorf("block %x is not found", i.currentHash)
+            }
+            block, err := decodeBlock(i.currentHash, blockData)
+            if err != nil {
+                    return err
+            }
+
+            if i.forward {
+                    if !bytes.Equal(heights.Get(heightKey(block.Height)), block.Hash) {
+                            return fmt.Errorf("block %x is not on the main chain", block.Hash)
+                    }
+                    i.currentHash = append([]byte(nil), heights.Get(heightKey(block.Height+1))...)
+            } else {
+                    i.currentHash = block.PrevBlockHash
+            }
+            i.buffered = append(i.buffered, block)
+            i.limit--
+    }
+
+    return nil
+}
@@ -0,0 +1,54 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// heights collects the heights of the blocks an iterator returns
+func heights(t *testing.T, bci *BlockchainIterator) []int {
+    var result []int
+    for {
+            block, err := bci.Next()
+            if err == ErrEndOfChain {
+                    return result
+            }
+            if !assert.Nil(t, err) {
+                    return result
+            }
+            result = append(result, block.Height)
+    }
+}
+
+func TestBlockchainIterator(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, dbFile)
+    defer bc.db.Close()
+    var blocks []*block.Block
+    for i := 1; i <= 5; i++ {
+            blocks = append(blocks, mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)}))
+    }
+
+    assert.Equal(t, []int{5, 4, 3, 2, 1, 0}, heights(t, bc.Iterator()))
+    assert.Equal(t, []int{0, 1, 2, 3, 4, 5}, heights(t, bc.IteratorFrom(blocks[0].PrevBlockHash, true).WithBatch(4)))
+    assert.Equal(t, []int{3, 2}, heights(t, bc.IteratorFrom(blocks[2].Hash, false).WithLimit(2)))
+
+    bci, err := bc.IteratorFromHeight(2, true)
+    assert.Nil(t, err)
+    assert.Equal(t, []int{2, 3, 4}, heights(t, bci.WithLimit(3).WithBatch(2)))
+    _, err = bc.IteratorFromHeight(6, true)
+    assert.NotNil(t, err)
+
+    bci = bc.IteratorFrom([]byte("unknown"), false)
+    _, err = bci.Next()
+    assert.NotNil(t, err)
+    assert.NotEqual(t, ErrEndOfChain, err, "Missing blocks are errors")
+}
@@ -0,0 +1,118 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// mineOn mines a block on top of parent without adding it, as another node would
+func mineOn(parent *block.Block, miner string, txs ...*transaction.Transaction) *block.Block {
+    coinbase := transaction.NewCoinbaseTX(miner, "", parent.Height+1, 0)
+    return block.NewBlockAt(append([]*transaction.Transaction{coinbase}, txs...), parent.Hash, parent.Height+1, parent.Bits, parent.Timestamp+1)
+}
+
+func hashes(blocks ...*block.Block) [][]byte {
+    var result [][]byte
+    for _, b := range blocks {
+            result = append(result, b.Hash)
+    }
+    return result
+}
+
+func TestReorganize(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    address := string(miner.GetAddress())
+    receiver := wallet.NewWallet()
+    bc := createBlockchain(t, address, dbFile)
+    defer bc.db.Close()
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+    balance := func(w *wallet.Wallet) int {
+            total := 0
+            for _, out := range UTXOSet.FindUTXO(wallet.HashPubKey(w.PublicKey)) {
+                    total += out.Value
+            }
+            return total
+    }
+    genesis, err := bc.GetBlock(bc.Tip())
+    assert.Nil(t, err)
+
+    // the main chain spends the genesis coinbase in its second block
+    a1 := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(address, "", 1, 0)})
+    spend := spendCoinbase(bc, miner, &genesis, string(receiver.GetAddress()), 5, 1)
+    a2 := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(address, "", 2, 1), spend})
+    assert.Equal(t, 5, balance(receiver))
+
+    // a competing branch from the genesis block, mined at the same difficulty
+    b1 := mineOn(&genesis, address)
+    b2 := mineOn(b1, address)
+    b3 := mineOn(b2, address)
+    b4 := mineOn(b3, address)
+
+    connected, disconnected, err := bc.AddBlock(b1)
+    assert.Nil(t, err)
+    assert.Empty(t, connected, "A branch with less work stays on the side")
+    assert.Empty(t, disconnected)
+    connected, _, err = bc.AddBlock(b2)
+    assert.Nil(t, err)
+    assert.Empty(t, connected, "As much work as the main chain isn't enough")
+    assert.Equal(t, a2.Hash, bc.Tip())
+    connected, _, err = bc.AddBlock(b4)
+    assert.Nil(t, err)
+    assert.Empty(t, connected, "Blocks without their parent wait as orphans")
+    assert.Equal(t, a2.Hash, bc.Tip())
+
+    // b3 connects the orphan b4 along with it, the branch now has more work
+    connected, disconnected, err = bc.AddBlock(b3)
+    assert.Nil(t, err)
+    assert.Equal(t, hashes(b1, b2, b3, b4), hashes(connected...))
+    assert.Equal(t, hashes(a2, a1), hashes(disconnected...), "Disconnected from the tip down")
+    assert.Equal(t, b4.Hash, bc.Tip())
+    assert.Equal(t, 4, bc.GetBestHeight())
+
+    assert.Equal(t, 0, balance(receiver), "The spend went away with its block")
+    assert.Equal(t, 5*transaction.GetBlockSubsidy(0), balance(miner), "Only the new branch's coinbases are left")
+    _, err = bc.FindTransaction(spend.ID)
+    assert.Equal(t, ErrTransactionNotFound, err)
+
+    // the node puts the transactions of disconnected blocks back in the mempool
+    mempool := NewMempool(DefaultMempoolSize, DefaultMempoolExpiry)
+    for _, b := range connected {
+            mempool.RemoveBlock(b)
+    }
+    for _, b := range disconnected {
+            for _, tx := range b.Transactions {
+                    if !tx.IsCoinbase() {
+                            assert.Nil(t, mempool.Add(tx, bc))
+                    }
+            }
+    }
+    assert.True(t, mempool.Has(spend.ID), "The spend is valid on the new branch too")
+    assert.Equal(t, 1, mempool.Count())
+}
+
+func TestBlockLocator(t *testing.T) {
+    // a chain of 100 blocks whose hashes are their heights
+    parent := func(hash []byte) []byte {
+            if hash[0] == 0 {
+                    return nil
+            }
+            return []byte{hash[0] - 1}
+    }
+
+    var heights []int
+    for _, hash := range Locator([]byte{99}, parent) {
+            heights = append(heights, int(hash[0]))
+    }
+    assert.Equal(t, []int{99, 98, 97, 96, 95, 94, 93, 92, 91, 90, 89, 87, 83, 75, 59, 27, 0}, heights)
+
+    assert.Equal(t, [][]byte{{0}}, Locator([]byte{0}, parent), "The genesis block is listed once")
+}
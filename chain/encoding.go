@@ -0,0 +1,46 @@
+package chain
+
+import (
+    "bytes"
+    "fmt"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+)
+
+// encodingKey in the blocks bucket holds the version of the encoding blocks are stored in,
+// databases without it have their blocks gob encoded, see checkEncoding
+var encodingKey = []byte("encoding")
+
+const blockEncoding = 1 // the canonical encoding of block.Serialize
+
+// putEncoding records that the blocks are stored in the canonical encoding
+func putEncoding(dbTx *bolt.Tx) error {
+    return dbTx.Bucket([]byte(blocksBucket)).Put(encodingKey, []byte{blockEncoding})
+}
+
+// checkEncoding refuses databases written before the canonical encoding. Their blocks'
+// hashes were computed over a layout without a header, they can't be kept as they are
+// and re-hashing them would make a chain no other node has, so the chain is downloaded again.
+func checkEncoding(dbTx *bolt.Tx) error {
+    if dbTx.Bucket([]byte(blocksBucket)).Get(encodingKey) == nil {
+            return ErrLegacyBlockchain
+    }
+
+    return nil
+}
+
+// decodeBlock decodes a block read from the blocks bucket under hash. Blocks are only
+// stored under the hash of their header, anything else means the DB is corrupted.
+func decodeBlock(hash, data []byte) (*block.Block, error) {
+    blk, err := block.DeserializeBlock(data)
+    if err != nil {
+            return nil, err
+    }
+    if !bytes.Equal(blk.Hash, hash) {
+            return nil, fmt.Errorf("block stored under %x hashes to %x", hash, blk.Hash)
+    }
+
+    return blk, nil
+}
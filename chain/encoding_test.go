@@ -0,0 +1,52 @@
+package chain
+
+import (
+    "bytes"
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// copyDB copies a database from testdata, for the test to open it
+func copyDB(t *testing.T, path string) string {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+            t.Fatal(err)
+    }
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+    if err = ioutil.WriteFile(dbFile, data, 0600); err != nil {
+            t.Fatal(err)
+    }
+    return dbFile
+}
+
+func TestLegacyBlockchain(t *testing.T) {
+    // blockchain_legacy.db was written when blocks were gob encoded without a header
+    dbFile := copyDB(t, "testdata/blockchain_legacy.db")
+    before, err := ioutil.ReadFile(dbFile)
+    assert.Nil(t, err)
+
+    _, err = NewBlockchainFile(dbFile)
+    assert.Equal(t, ErrLegacyBlockchain, err, "Old databases have to be resynced")
+    after, err := ioutil.ReadFile(dbFile)
+    assert.Nil(t, err)
+    assert.True(t, bytes.Equal(before, after), "The database is left as it was")
+}
+
+func TestDecodeBlock(t *testing.T) {
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.db.Close()
+    blk := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", 1, 0)})
+
+    decoded, err := decodeBlock(blk.Hash, blk.Serialize())
+    assert.Nil(t, err)
+    assert.Equal(t, blk.Hash, decoded.Hash)
+    _, err = decodeBlock(blk.PrevBlockHash, blk.Serialize())
+    assert.NotNil(t, err, "A block must be stored under its own hash")
+}
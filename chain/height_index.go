@@ -0,0 +1,101 @@
+package chain
+
+import (
+    "bytes"
+    "encoding/binary"
+    "log"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+)
+
+const heightsBucket = "heights" // height -> hash of the main chain block at that height
+
+// heightKey makes the keys sort by height
+func heightKey(height int) []byte {
+    key := make([]byte, 4)
+    binary.BigEndian.PutUint32(key, uint32(height))
+
+    return key
+}
+
+// indexHeight records a block being connected as the main chain block at its height
+func indexHeight(dbTx *bolt.Tx, block *block.Block) {
+    err := dbTx.Bucket([]byte(heightsBucket)).Put(heightKey(block.Height), block.Hash)
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// unindexHeight reverts indexHeight for a block being disconnected
+func unindexHeight(dbTx *bolt.Tx, block *block.Block) {
+    err := dbTx.Bucket([]byte(heightsBucket)).Delete(heightKey(block.Height))
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// indexMainChainHeights builds the height index of databases created before there was one
+func indexMainChainHeights(dbTx *bolt.Tx, tip []byte) {
+    _, err := dbTx.CreateBucket([]byte(heightsBucket))
+    if err != nil {
+            log.Panic(err)
+    }
+
+    for hash := tip; len(hash) != 0; {
+            block := getBlockTx(dbTx, hash)
+            indexHeight(dbTx, block)
+            hash = block.PrevBlockHash
+    }
+}
+
+// GetBlockByHeight returns the main chain block at the height
+func (bc *Blockchain) GetBlockByHeight(height int) (block.Block, error) {
+    var block block.Block
+
+    err := bc.db.View(func(tx *bolt.Tx) error {
+            hash := tx.Bucket([]byte(heightsBucket)).Get(heightKey(height))
+            if height < 0 || hash == nil {
+                    return ErrBlockNotFound
+            }
+            block = *getBlockTx(tx, hash)
+
+            return nil
+    })
+
+    return block, err
+}
+
+// Confirmations returns the number of main chain blocks from the block on, -1 if it isn't on the main chain
+func (bc *Blockchain) Confirmations(b *block.Block) int {
+    mainBlock, err := bc.GetBlockByHeight(b.Height)
+    if err != nil || !bytes.Equal(mainBlock.Hash, b.Hash) {
+            return -1
+    }
+
+    return bc.GetBestHeight() - b.Height + 1
+}
+
+// RangeBlocks calls f with the main chain blocks from height from to height to, going forward,
+// until f returns false. It runs in a single read transaction, so f can't write to the DB.
+// It fails if a block can't be read, f has then seen the blocks before it.
+func (bc *Blockchain) RangeBlocks(from, to int, f func(*block.Block) bool) error {
+    if from < 0 {
+            from = 0
+    }
+
+    return bc.db.View(func(tx *bolt.Tx) error {
+            c := tx.Bucket([]byte(heightsBucket)).Cursor()
+            for k, hash := c.Seek(heightKey(from)); k != nil && int(binary.BigEndian.Uint32(k)) <= to; k, hash = c.Next() {
+                    blk, err := readBlockTx(tx, hash)
+                    if err != nil {
+                            return err
+                    }
+                    if !f(blk) {
+                            break
+                    }
+            }
+            return nil
+    })
+}
@@ -0,0 +1,73 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/boltdb/bolt"
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestHeightIndex(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, dbFile)
+    defer bc.db.Close()
+
+    var blocks []*block.Block
+    for i := 1; i <= 5; i++ {
+            blocks = append(blocks, mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)}))
+    }
+
+    blk, err := bc.GetBlockByHeight(3)
+    assert.Nil(t, err)
+    assert.Equal(t, blocks[2].Hash, blk.Hash)
+    _, err = bc.GetBlockByHeight(6)
+    assert.NotNil(t, err)
+
+    var heights []int
+    err = bc.RangeBlocks(2, 4, func(b *block.Block) bool {
+            heights = append(heights, b.Height)
+            return true
+    })
+    assert.Nil(t, err)
+    assert.Equal(t, []int{2, 3, 4}, heights, "Ranges go forward")
+
+    heights = nil
+    err = bc.RangeBlocks(0, 100, func(b *block.Block) bool {
+            heights = append(heights, b.Height)
+            return b.Height < 1
+    })
+    assert.Nil(t, err)
+    assert.Equal(t, []int{0, 1}, heights, "The callback stops the iteration")
+
+    // the locator's side branch block isn't on the main chain, the walk starts after the block below it
+    side := mineOn(blocks[1], miner)
+    _, _, err = bc.AddBlock(side)
+    assert.Nil(t, err)
+    assert.Equal(t, hashes(blocks[1], blocks[2]), bc.MainChainAfter([][]byte{side.Hash, blocks[0].Hash}, nil, 2))
+    assert.Equal(t, 6, len(bc.MainChainAfter(nil, nil, 100)), "Without a known locator hash it starts from genesis")
+    assert.Equal(t, hashes(blocks[3], blocks[4]), bc.MainChainAfter([][]byte{blocks[2].Hash}, blocks[4].Hash, 10))
+
+    disconnect(t, bc, blocks[4])
+    _, err = bc.GetBlockByHeight(5)
+    assert.NotNil(t, err, "Disconnected blocks leave the index")
+
+    // a height naming a block that isn't stored
+    err = bc.db.Update(func(tx *bolt.Tx) error {
+            return tx.Bucket([]byte(heightsBucket)).Put(heightKey(5), []byte("missing"))
+    })
+    assert.Nil(t, err)
+    heights = nil
+    err = bc.RangeBlocks(3, 5, func(b *block.Block) bool {
+            heights = append(heights, b.Height)
+            return true
+    })
+    assert.NotNil(t, err, "A block that can't be read is an error, not a panic")
+    assert.Equal(t, []int{3, 4}, heights)
+}
@@ -0,0 +1,272 @@
+package chain
+
+import (
+    "bytes"
+    "encoding/hex"
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+)
+
+const DefaultMempoolSize = 5 << 20       // bytes of transactions kept before evicting
+const DefaultMempoolExpiry = 24 * time.Hour
+const blockTemplateSize = 1 << 20       // bytes of transactions a miner puts in a block
+
+type mempoolEntry struct {
+    tx    *transaction.Transaction
+    fee   int
+    size  int
+    added time.Time
+}
+
+// paysMoreThan compares fee rates without dividing: fee/size > other.fee/other.size
+func (e *mempoolEntry) paysMoreThan(other *mempoolEntry) bool {
+    return e.fee*other.size > other.fee*e.size
+}
+
+// Mempool keeps validated transactions waiting to be mined.
+// Transactions are checked against the UTXO set when they come in, so they may
+// only spend confirmed outputs, and no two of them may spend the same output.
+// It is safe for concurrent use.
+type Mempool struct {
+    mu      sync.RWMutex
+    entries map[string]*mempoolEntry
+    spends  map[string]string // outpoint -> ID of the transaction spending it
+    size    int
+    maxSize int
+    expiry  time.Duration
+
+    onAdd []func(*transaction.Transaction) // guarded by mu
+}
+
+// NewMempool creates a mempool holding up to maxSize bytes of transactions for at most expiry
+func NewMempool(maxSize int, expiry time.Duration) *Mempool {
+    return &Mempool{
+            entries: make(map[string]*mempoolEntry),
+            spends:  make(map[string]string),
+            maxSize: maxSize,
+            expiry:  expiry,
+    }
+}
+
+func outpointKey(txid []byte, vout int) string {
+    return fmt.Sprintf("%x:%d", txid, vout)
+}
+
+// Add validates the transaction against the UTXO set and puts it in the mempool.
+// When the mempool grows over its size the transactions paying the lowest fee
+// rate are evicted, which may be the new one.
+func (mp *Mempool) Add(tx *transaction.Transaction, bc *Blockchain) error {
+    err := mp.add(tx, bc)
+    if err != nil {
+            return err
+    }
+
+    mp.mu.RLock()
+    listeners := mp.onAdd
+    mp.mu.RUnlock()
+    for _, f := range listeners {
+            f(tx)
+    }
+    return nil
+}
+
+// OnAdd registers f to be called with every transaction the mempool takes in, once it's in.
+// f runs on the goroutine adding the transaction, so it shouldn't block.
+func (mp *Mempool) OnAdd(f func(*transaction.Transaction)) {
+    mp.mu.Lock()
+    defer mp.mu.Unlock()
+
+    mp.onAdd = append(mp.onAdd, f)
+}
+
+func (mp *Mempool) add(tx *transaction.Transaction, bc *Blockchain) error {
+    if tx.IsCoinbase() {
+            return rejectTx(tx, RejectBadCoinbase, "coinbase transactions are only valid in blocks")
+    }
+    if bytes.Compare(tx.ID, tx.Hash()) != 0 {
+            return rejectTx(tx, RejectBadTxID, "ID doesn't match the transaction")
+    }
+
+    mp.mu.Lock()
+    defer mp.mu.Unlock()
+
+    mp.expire()
+    txID := hex.EncodeToString(tx.ID)
+    if mp.entries[txID] != nil {
+            return rejectTx(tx, RejectDuplicate, "already in the mempool")
+    }
+    for _, vin := range tx.Vin {
+            if spender, ok := mp.spends[outpointKey(vin.Txid, vin.Vout)]; ok {
+                    return rejectTx(tx, RejectMempoolConflict, "output %x:%d is already spent by %s", vin.Txid, vin.Vout, spender)
+            }
+    }
+
+    var fee int
+    err := bc.db.View(func(dbTx *bolt.Tx) error {
+            var err error
+            fee, err = checkTransaction(dbTx, tx, getTipTx(dbTx), nil)
+            return err
+    })
+    if err != nil {
+            return err
+    }
+
+    entry := &mempoolEntry{tx, fee, tx.Size(), time.Now()}
+    mp.entries[txID] = entry
+    mp.size += entry.size
+    for _, vin := range tx.Vin {
+            mp.spends[outpointKey(vin.Txid, vin.Vout)] = txID
+    }
+
+    for mp.size > mp.maxSize {
+            lowest := mp.lowestFeeRate()
+            mp.remove(hex.EncodeToString(lowest.tx.ID))
+            if lowest == entry {
+                    return rejectTx(tx, RejectMempoolFull, "fee rate too low for a full mempool")
+            }
+    }
+
+    return nil
+}
+
+// Has tells whether a transaction is in the mempool
+func (mp *Mempool) Has(id []byte) bool {
+    mp.mu.RLock()
+    defer mp.mu.RUnlock()
+
+    return mp.entries[hex.EncodeToString(id)] != nil
+}
+
+// Get returns a transaction from the mempool
+func (mp *Mempool) Get(id []byte) (*transaction.Transaction, bool) {
+    mp.mu.RLock()
+    defer mp.mu.RUnlock()
+
+    entry := mp.entries[hex.EncodeToString(id)]
+    if entry == nil {
+            return nil, false
+    }
+
+    return entry.tx, true
+}
+
+// Count returns the number of transactions in the mempool
+func (mp *Mempool) Count() int {
+    mp.mu.RLock()
+    defer mp.mu.RUnlock()
+
+    return len(mp.entries)
+}
+
+// Size returns the number of bytes of the transactions in the mempool
+func (mp *Mempool) Size() int {
+    mp.mu.RLock()
+    defer mp.mu.RUnlock()
+
+    return mp.size
+}
+
+// BlockTemplate picks the transactions paying the highest fee rate that fit in
+// blockTemplateSize and returns them along with the sum of their fees
+func (mp *Mempool) BlockTemplate() ([]*transaction.Transaction, int) {
+    mp.mu.Lock()
+    defer mp.mu.Unlock()
+
+    mp.expire()
+    var txs []*transaction.Transaction
+    fees, size := 0, 0
+    for _, entry := range mp.byFeeRate() {
+            if size+entry.size > blockTemplateSize {
+                    continue
+            }
+            txs = append(txs, entry.tx)
+            fees += entry.fee
+            size += entry.size
+    }
+
+    return txs, fees
+}
+
+// Transactions returns the transactions in the mempool, best fee rates first
+func (mp *Mempool) Transactions() []*transaction.Transaction {
+    mp.mu.RLock()
+    defer mp.mu.RUnlock()
+
+    var txs []*transaction.Transaction
+    for _, entry := range mp.byFeeRate() {
+            txs = append(txs, entry.tx)
+    }
+
+    return txs
+}
+
+// byFeeRate returns the entries, best fee rates first
+func (mp *Mempool) byFeeRate() []*mempoolEntry {
+    var entries []*mempoolEntry
+    for _, entry := range mp.entries {
+            entries = append(entries, entry)
+    }
+    sort.Slice(entries, func(i, j int) bool {
+            return entries[i].paysMoreThan(entries[j])
+    })
+
+    return entries
+}
+
+// RemoveBlock drops the transactions of a block, and those spending the same outputs
+func (mp *Mempool) RemoveBlock(block *block.Block) {
+    mp.mu.Lock()
+    defer mp.mu.Unlock()
+
+    for _, tx := range block.Transactions {
+            mp.remove(hex.EncodeToString(tx.ID))
+            if tx.IsCoinbase() {
+                    continue
+            }
+            for _, vin := range tx.Vin {
+                    if spender, ok := mp.spends[outpointKey(vin.Txid, vin.Vout)]; ok {
+                            mp.remove(spender)
+                    }
+            }
+    }
+}
+
+func (mp *Mempool) remove(txID string) {
+    entry := mp.entries[txID]
+    if entry == nil {
+            return
+    }
+
+    for _, vin := range entry.tx.Vin {
+            delete(mp.spends, outpointKey(vin.Txid, vin.Vout))
+    }
+    mp.size -= entry.size
+    delete(mp.entries, txID)
+}
+
+func (mp *Mempool) lowestFeeRate() *mempoolEntry {
+    var lowest *mempoolEntry
+    for _, entry := range mp.entries {
+            if lowest == nil || lowest.paysMoreThan(entry) {
+                    lowest = entry
+            }
+    }
+
+    return lowest
+}
+
+// expire drops the transactions that have waited longer than the expiry
+func (mp *Mempool) expire() {
+    for txID, entry := range mp.entries {
+            if time.Since(entry.added) > mp.expiry {
+                    mp.remove(txID)
+            }
+    }
+}
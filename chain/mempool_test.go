@@ -0,0 +1,137 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/boltdb/bolt"
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// spendCoinbase creates a transaction moving the coinbase output of block to address
+func spendCoinbase(bc *Blockchain, w *wallet.Wallet, block *block.Block, to string, amount, fee int) *transaction.Transaction {
+    coinbase := block.Transactions[0]
+    change := coinbase.Vout[0].Value - amount - fee
+    inputs := []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}}
+    outputs := []transaction.TXOutput{*transaction.NewTXOutput(amount, to), *transaction.NewTXOutput(change, string(w.GetAddress()))}
+
+    tx := transaction.Transaction{Vin: inputs, Vout: outputs}
+    err := bc.SignTransaction(&tx, w.PrivateKey)
+    if err != nil {
+            panic(err)
+    }
+    tx.ID = tx.Hash()
+
+    return &tx
+}
+
+// createBlockchain, newBlockchain and mineBlock stop the test when the chain can't be opened or the block added
+func createBlockchain(t *testing.T, address, dbFile string) *Blockchain {
+    bc, err := CreateBlockchainFile(dbFile, address)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return bc
+}
+
+func newBlockchain(t *testing.T, dbFile string) *Blockchain {
+    bc, err := NewBlockchainFile(dbFile)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return bc
+}
+
+func mineBlock(t *testing.T, bc *Blockchain, txs []*transaction.Transaction) *block.Block {
+    block, _, _, err := bc.MineBlock(txs, nil)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return block
+}
+
+// disconnect takes the tip block off the main chain, as a reorganization does
+func disconnect(t *testing.T, bc *Blockchain, b *block.Block) {
+    err := bc.db.Update(func(tx *bolt.Tx) error {
+            disconnectBlock(tx, b)
+            return nil
+    })
+    if err != nil {
+            t.Fatal(err)
+    }
+}
+
+func TestMempool(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    to := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, string(miner.GetAddress()), dbFile)
+    defer bc.db.Close()
+
+    var blocks []*block.Block
+    for i := 1; i <= 3; i++ {
+            blocks = append(blocks, mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", i, 0)}))
+    }
+
+    cheap := spendCoinbase(bc, miner, blocks[0], to, 5, 1)
+    generous := spendCoinbase(bc, miner, blocks[1], to, 5, 3)
+    conflicting := spendCoinbase(bc, miner, blocks[0], to, 4, 1)
+    middle := spendCoinbase(bc, miner, blocks[2], to, 5, 2)
+
+    mempool := NewMempool(DefaultMempoolSize, DefaultMempoolExpiry)
+    assert.Nil(t, mempool.Add(cheap, bc))
+    assert.Nil(t, mempool.Add(generous, bc))
+
+    err := mempool.Add(cheap, bc)
+    assert.Equal(t, RejectDuplicate, err.(*TxError).Reason, "Duplicates are rejected")
+    err = mempool.Add(conflicting, bc)
+    assert.Equal(t, RejectMempoolConflict, err.(*TxError).Reason, "Double spends are rejected")
+
+    forged := spendCoinbase(bc, miner, blocks[2], to, 5, 2)
+    forged.Vout[0].Value = 9
+    forged.ID = forged.Hash()
+    err = mempool.Add(forged, bc)
+    assert.Equal(t, RejectBadTransaction, err.(*TxError).Reason, "Bad signatures are rejected")
+
+    txs, fees := mempool.BlockTemplate()
+    assert.Equal(t, []*transaction.Transaction{generous, cheap}, txs, "Template is ordered by fee rate")
+    assert.Equal(t, 4, fees)
+
+    // a block spending the same output as cheap takes it out of the mempool
+    block := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 4, 1), conflicting})
+    mempool.RemoveBlock(block)
+    assert.False(t, mempool.Has(cheap.ID), "Conflicting transactions are removed with a block")
+    assert.True(t, mempool.Has(generous.ID))
+
+    full := NewMempool(generous.Size()+middle.Size()-1, DefaultMempoolExpiry)
+    assert.Nil(t, full.Add(generous, bc))
+    err = full.Add(middle, bc)
+    assert.Equal(t, RejectMempoolFull, err.(*TxError).Reason, "Lowest fee rate is evicted")
+    assert.False(t, full.Has(middle.ID))
+    assert.True(t, full.Has(generous.ID))
+    assert.Equal(t, generous.Size(), full.Size())
+
+    expiring := NewMempool(DefaultMempoolSize, time.Nanosecond)
+    assert.Nil(t, expiring.Add(generous, bc))
+    time.Sleep(time.Millisecond)
+    txs, _ = expiring.BlockTemplate()
+    assert.Empty(t, txs, "Stale transactions expire")
+
+    // the miner's coinbase is built for the height the block gets
+    mined, connected, _, err := bc.MineBlock([]*transaction.Transaction{generous}, func(height int) *transaction.Transaction {
+            return transaction.NewCoinbaseTX(string(miner.GetAddress()), "", height, 3)
+    })
+    assert.Nil(t, err)
+    if assert.Len(t, connected, 1) {
+            assert.Equal(t, mined.Hash, connected[0].Hash)
+    }
+    assert.Equal(t, 5, mined.Height)
+    assert.True(t, mined.Transactions[0].IsCoinbase())
+    assert.Equal(t, transaction.GetBlockSubsidy(5)+3, mined.Transactions[0].Vout[0].Value)
+}
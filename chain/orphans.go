@@ -0,0 +1,126 @@
+package chain
+
+import (
+    "encoding/hex"
+    "sync"
+    "time"
+
+    "blockchain_go/block"
+)
+
+const maxOrphans = 256 // blocks waiting for their parent, more than a sync has in flight
+const orphanExpiry = time.Hour
+
+type orphan struct {
+    block *block.Block
+    added time.Time
+}
+
+// orphanPool keeps blocks whose parent isn't known yet in memory, by parent hash.
+// They're only written to the DB once their parent connects, so orphans nobody
+// builds on cost nothing but their place in the pool until they're evicted.
+// It is safe for concurrent use.
+type orphanPool struct {
+    mu       sync.Mutex
+    blocks   map[string]*orphan  // by hash
+    byParent map[string][]string // parent hash -> hashes of the orphans waiting for it
+    max      int
+    expiry   time.Duration
+}
+
+func newOrphanPool(max int, expiry time.Duration) *orphanPool {
+    return &orphanPool{
+            blocks:   make(map[string]*orphan),
+            byParent: make(map[string][]string),
+            max:      max,
+            expiry:   expiry,
+    }
+}
+
+// add puts a block in the pool, evicting the oldest orphan when it's full
+func (op *orphanPool) add(blk *block.Block) {
+    op.mu.Lock()
+    defer op.mu.Unlock()
+
+    op.expire()
+    hash := hex.EncodeToString(blk.Hash)
+    if op.blocks[hash] != nil {
+            return
+    }
+    if len(op.blocks) >= op.max {
+            var oldest string
+            for h, o := range op.blocks {
+                    if oldest == "" || o.added.Before(op.blocks[oldest].added) {
+                            oldest = h
+                    }
+            }
+            op.remove(oldest)
+    }
+
+    op.blocks[hash] = &orphan{blk, time.Now()}
+    parent := hex.EncodeToString(blk.PrevBlockHash)
+    op.byParent[parent] = append(op.byParent[parent], hash)
+}
+
+// has tells whether a block is waiting in the pool
+func (op *orphanPool) has(hash []byte) bool {
+    op.mu.Lock()
+    defer op.mu.Unlock()
+
+    return op.blocks[hex.EncodeToString(hash)] != nil
+}
+
+// take removes and returns the orphans waiting for the given parent
+func (op *orphanPool) take(parent []byte) []*block.Block {
+    op.mu.Lock()
+    defer op.mu.Unlock()
+
+    op.expire()
+    var orphans []*block.Block
+    key := hex.EncodeToString(parent)
+    for _, hash := range op.byParent[key] {
+            orphans = append(orphans, op.blocks[hash].block)
+            delete(op.blocks, hash)
+    }
+    delete(op.byParent, key)
+
+    return orphans
+}
+
+// drop removes the orphans built on top of a block, and those built on top of them
+func (op *orphanPool) drop(parent []byte) {
+    for _, orphan := range op.take(parent) {
+            op.drop(orphan.Hash)
+    }
+}
+
+func (op *orphanPool) remove(hash string) {
+    o := op.blocks[hash]
+    if o == nil {
+            return
+    }
+
+    parent := hex.EncodeToString(o.block.PrevBlockHash)
+    siblings := op.byParent[parent]
+    for i, h := range siblings {
+            if h == hash {
+                    siblings = append(siblings[:i], siblings[i+1:]...)
+                    break
+            }
+    }
+    if len(siblings) == 0 {
+            delete(op.byParent, parent)
+    } else {
+            op.byParent[parent] = siblings
+    }
+    delete(op.blocks, hash)
+}
+
+// expire drops the orphans that have waited longer than the expiry
+func (op *orphanPool) expire() {
+    for hash, o := range op.blocks {
+            if time.Since(o.added) > op.expiry {
+                    op.remove(hash)
+            }
+    }
+}
@@ -0,0 +1,72 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestOrphanPool(t *testing.T) {
+    blk := func(hash, parent byte) *block.Block {
+            return &block.Block{Header: block.Header{PrevBlockHash: []byte{parent}}, Hash: []byte{hash}}
+    }
+
+    pool := newOrphanPool(3, time.Hour)
+    pool.add(blk(1, 0))
+    pool.add(blk(2, 1))
+    pool.add(blk(3, 1))
+    pool.add(blk(4, 3))
+    assert.False(t, pool.has([]byte{1}), "The oldest orphan is evicted when the pool is full")
+    assert.True(t, pool.has([]byte{4}))
+
+    assert.Equal(t, hashes(blk(2, 1), blk(3, 1)), hashes(pool.take([]byte{1})...), "Orphans are found by their parent")
+    assert.False(t, pool.has([]byte{2}))
+    assert.Empty(t, pool.take([]byte{1}))
+
+    pool.add(blk(5, 4))
+    pool.drop([]byte{3})
+    assert.False(t, pool.has([]byte{4}), "Dropping a block drops the orphans built on it")
+    assert.False(t, pool.has([]byte{5}))
+
+    pool = newOrphanPool(3, 0)
+    pool.add(blk(1, 0))
+    assert.Empty(t, pool.take([]byte{0}), "Orphans expire")
+}
+
+func TestOrphanDifficulty(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, dbFile)
+    defer bc.db.Close()
+    // blocks mined this fast make the first retarget as hard as it gets
+    var tip *block.Block
+    for i := 1; i <= block.RetargetInterval; i++ {
+            tip = mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)})
+    }
+    assert.True(t, block.CompactToBig(tip.Bits).Cmp(block.PowLimit) < 0)
+
+    orphanAt := func(height int) *block.Block {
+            coinbase := transaction.NewCoinbaseTX(miner, "", height, 0)
+            return block.NewBlock([]*transaction.Transaction{coinbase}, make([]byte, 32), height, block.BigToCompact(block.PowLimit))
+    }
+    cheap := orphanAt(tip.Height + 1)
+    _, _, err := bc.AddBlock(cheap)
+    if assert.IsType(t, &BlockError{}, err) {
+            assert.Equal(t, RejectBadDifficulty, err.(*BlockError).Reason, "An orphan can't be easier than our tip in the same retarget period")
+    }
+    assert.False(t, bc.HasBlock(cheap.Hash))
+
+    later := orphanAt(tip.Height + block.RetargetInterval)
+    _, _, err = bc.AddBlock(later)
+    assert.Nil(t, err, "A retarget later the difficulty may have gone down")
+    assert.True(t, bc.HasBlock(later.Hash))
+    _, err = bc.GetBlock(later.Hash)
+    assert.Equal(t, ErrBlockNotFound, err, "Orphans aren't written to the DB")
+}
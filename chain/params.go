@@ -0,0 +1,63 @@
+package chain
+
+import (
+    "encoding/json"
+    "io/ioutil"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+)
+
+// Params are the consensus parameters a network may choose. Every node of the network
+// has to use the same values, from before the chain is created or opened. They can be
+// read from a JSON file such as
+//
+//    {"targetblockinterval": 600, "retargetinterval": 2016, "initialsubsidy": 50, "halvinginterval": 210000}
+//
+// where the parameters left out keep their default.
+type Params struct {
+    TargetBlockInterval int64 `json:"targetblockinterval"` // seconds we aim for between two blocks
+    RetargetInterval    int   `json:"retargetinterval"`    // blocks after which the difficulty is adjusted
+    InitialSubsidy      int   `json:"initialsubsidy"`      // coins a block creates before the first halving
+    HalvingInterval     int   `json:"halvinginterval"`     // blocks after which the subsidy is halved
+}
+
+// CurrentParams returns the parameters in use
+func CurrentParams() Params {
+    retarget := block.GetParams()
+    emission := transaction.GetParams()
+
+    return Params{retarget.TargetBlockInterval, retarget.RetargetInterval, emission.InitialSubsidy, emission.HalvingInterval}
+}
+
+// SetParams makes the node use the parameters. It fails, changing nothing, if one of them is out of range.
+func SetParams(p Params) error {
+    oldRetarget := block.GetParams()
+    err := block.SetParams(block.Params{TargetBlockInterval: p.TargetBlockInterval, RetargetInterval: p.RetargetInterval})
+    if err != nil {
+            return err
+    }
+    err = transaction.SetParams(transaction.Params{InitialSubsidy: p.InitialSubsidy, HalvingInterval: p.HalvingInterval})
+    if err != nil {
+            block.SetParams(oldRetarget)
+            return err
+    }
+
+    return nil
+}
+
+// LoadParams reads a params file and sets the parameters it has
+func LoadParams(path string) error {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+            return err
+    }
+
+    params := CurrentParams()
+    err = json.Unmarshal(data, &params)
+    if err != nil {
+            return err
+    }
+
+    return SetParams(params)
+}
@@ -0,0 +1,28 @@
+package chain
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+)
+
+func TestLoadParams(t *testing.T) {
+    defer SetParams(CurrentParams())
+    defaults := CurrentParams()
+
+    file := filepath.Join(t.TempDir(), "params.json")
+    assert.Nil(t, ioutil.WriteFile(file, []byte(`{"retargetinterval": 2016, "initialsubsidy": 50}`), 0600))
+    assert.Nil(t, LoadParams(file))
+    assert.Equal(t, Params{defaults.TargetBlockInterval, 2016, 50, defaults.HalvingInterval}, CurrentParams(), "Parameters left out keep their value")
+    assert.Equal(t, 2016, block.RetargetInterval)
+    assert.Equal(t, 50, transaction.GetBlockSubsidy(0))
+
+    assert.Nil(t, ioutil.WriteFile(file, []byte(`{"retargetinterval": 10, "halvinginterval": -1}`), 0600))
+    assert.NotNil(t, LoadParams(file))
+    assert.Equal(t, 2016, block.RetargetInterval, "Nothing changes when a parameter is out of range")
+}
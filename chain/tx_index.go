@@ -0,0 +1,90 @@
+package chain
+
+import (
+    "bytes"
+    "encoding/binary"
+    "log"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+)
+
+const txIndexBucket = "txindex" // txid -> hash of the main chain block it is in + its position in the block
+
+// indexTransactions adds the transactions of a block being connected to the transaction index
+func indexTransactions(dbTx *bolt.Tx, block *block.Block) {
+    b := dbTx.Bucket([]byte(txIndexBucket))
+    for i, tx := range block.Transactions {
+            location := make([]byte, len(block.Hash)+4)
+            copy(location, block.Hash)
+            binary.BigEndian.PutUint32(location[len(block.Hash):], uint32(i))
+
+            err := b.Put(tx.ID, location)
+            if err != nil {
+                    log.Panic(err)
+            }
+    }
+}
+
+// unindexTransactions reverts indexTransactions for a block being disconnected
+func unindexTransactions(dbTx *bolt.Tx, block *block.Block) {
+    b := dbTx.Bucket([]byte(txIndexBucket))
+    for _, tx := range block.Transactions {
+            err := b.Delete(tx.ID)
+            if err != nil {
+                    log.Panic(err)
+            }
+    }
+}
+
+// lookupTransactionTx finds a main chain transaction and its block with the transaction index, both nil if it isn't there
+func lookupTransactionTx(dbTx *bolt.Tx, ID []byte) (*block.Block, *transaction.Transaction) {
+    location := dbTx.Bucket([]byte(txIndexBucket)).Get(ID)
+    if location == nil {
+            return nil, nil
+    }
+    hash := location[:len(location)-4]
+    position := binary.BigEndian.Uint32(location[len(hash):])
+
+    block := getBlockTx(dbTx, hash)
+    if int(position) >= len(block.Transactions) || !bytes.Equal(block.Transactions[position].ID, ID) {
+            log.Panic("ERROR: Transaction index doesn't match the block")
+    }
+
+    return block, block.Transactions[position]
+}
+
+// indexMainChainTransactions builds the transaction index of databases created before there was one
+func indexMainChainTransactions(dbTx *bolt.Tx, tip []byte) {
+    _, err := dbTx.CreateBucket([]byte(txIndexBucket))
+    if err != nil {
+            log.Panic(err)
+    }
+
+    for hash := tip; len(hash) != 0; {
+            block := getBlockTx(dbTx, hash)
+            indexTransactions(dbTx, block)
+            hash = block.PrevBlockHash
+    }
+}
+
+// GetTransaction returns a main chain transaction along with the block confirming it
+func (bc *Blockchain) GetTransaction(ID []byte) (*transaction.Transaction, *block.Block, error) {
+    var block *block.Block
+    var tx *transaction.Transaction
+
+    err := bc.db.View(func(dbTx *bolt.Tx) error {
+            block, tx = lookupTransactionTx(dbTx, ID)
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+    if tx == nil {
+            return nil, nil, ErrTransactionNotFound
+    }
+
+    return tx, block, nil
+}
@@ -0,0 +1,43 @@
+package chain
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/boltdb/bolt"
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestTransactionIndex(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    bc := createBlockchain(t, string(miner.GetAddress()), dbFile)
+    first := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 1, 0)})
+    spend := spendCoinbase(bc, miner, first, string(wallet.NewWallet().GetAddress()), 5, 1)
+    block := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 2, 1), spend})
+
+    tx, confirming, err := bc.GetTransaction(spend.ID)
+    assert.Nil(t, err)
+    assert.Equal(t, spend.ID, tx.ID)
+    assert.Equal(t, block.Hash, confirming.Hash)
+
+    // databases without the index get it when opened
+    err = bc.db.Update(func(tx *bolt.Tx) error {
+            return tx.DeleteBucket([]byte(txIndexBucket))
+    })
+    assert.Nil(t, err)
+    bc.db.Close()
+    bc = newBlockchain(t, dbFile)
+    defer bc.db.Close()
+    found, err := bc.FindTransaction(first.Transactions[0].ID)
+    assert.Nil(t, err)
+    assert.Equal(t, first.Transactions[0].ID, found.ID)
+
+    disconnect(t, bc, block)
+    _, err = bc.FindTransaction(spend.ID)
+    assert.NotNil(t, err, "Disconnected transactions leave the index")
+}
@@ -0,0 +1,266 @@
+package chain
+
+import (
+    "bytes"
+    "encoding/hex"
+    "fmt"
+    "time"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+)
+
+// RejectReason tells why a block or transaction was rejected
+type RejectReason string
+
+const (
+    RejectNoTransactions   RejectReason = "no-transactions"
+    RejectBadPoW           RejectReason = "bad-pow"
+    RejectUnknownParent    RejectReason = "unknown-parent"
+    RejectBadHeight        RejectReason = "bad-height"
+    RejectBadDifficulty    RejectReason = "bad-difficulty"
+    RejectTimeTooOld       RejectReason = "time-too-old"
+    RejectTimeTooNew       RejectReason = "time-too-new"
+    RejectBadMerkleRoot    RejectReason = "bad-merkle-root"
+    RejectBadTxID          RejectReason = "bad-txid"
+    RejectBadCoinbase      RejectReason = "bad-coinbase"
+    RejectBadCoinbaseValue RejectReason = "bad-coinbase-value"
+    RejectBadTransaction   RejectReason = "bad-transaction"
+    RejectMissingInput     RejectReason = "missing-input"
+    RejectDoubleSpend      RejectReason = "double-spend"
+    RejectDuplicate        RejectReason = "duplicate"
+    RejectMempoolConflict  RejectReason = "mempool-conflict"
+    RejectMempoolFull      RejectReason = "mempool-full"
+)
+
+// TxError is returned when a transaction is invalid or can't be accepted
+type TxError struct {
+    ID     []byte
+    Reason RejectReason
+    Detail string
+}
+
+func (e *TxError) Error() string {
+    return fmt.Sprintf("transaction %x rejected (%s): %s", e.ID, e.Reason, e.Detail)
+}
+
+func rejectTx(tx *transaction.Transaction, reason RejectReason, format string, a ...interface{}) *TxError {
+    return &TxError{tx.ID, reason, fmt.Sprintf(format, a...)}
+}
+
+// BlockError is returned when a block breaks a consensus rule
+type BlockError struct {
+    Hash   []byte
+    Reason RejectReason
+    Detail string
+}
+
+func (e *BlockError) Error() string {
+    return fmt.Sprintf("block %x rejected (%s): %s", e.Hash, e.Reason, e.Detail)
+}
+
+// Excusable tells whether an honest peer could have sent the block: its parent may be
+// on a branch we've reorganized away from, and our clock may be behind the sender's
+func (e *BlockError) Excusable() bool {
+    return e.Reason == RejectUnknownParent || e.Reason == RejectTimeTooNew
+}
+
+func rejectBlock(block *block.Block, reason RejectReason, format string, a ...interface{}) *BlockError {
+    return &BlockError{block.Hash, reason, fmt.Sprintf(format, a...)}
+}
+
+// checkBlock runs the checks that don't depend on where the block sits in the chain
+func checkBlock(blk *block.Block) error {
+    if len(blk.Transactions) == 0 {
+            return rejectBlock(blk, RejectNoTransactions, "block has no transactions")
+    }
+    if !block.NewProofOfWork(blk).Validate() {
+            return rejectBlock(blk, RejectBadPoW, "hash doesn't match the header or is above the target")
+    }
+    // the PoW only covers the header, the transactions are tied to it by the Merkle root
+    if bytes.Compare(blk.MerkleRoot, blk.HashTransactions()) != 0 {
+            return rejectBlock(blk, RejectBadMerkleRoot, "Merkle root doesn't match the transactions")
+    }
+
+    coinbases := 0
+    spent := make(map[string]bool)
+    for _, tx := range blk.Transactions {
+            if bytes.Compare(tx.ID, tx.Hash()) != 0 {
+                    return rejectBlock(blk, RejectBadTxID, "transaction %x has a wrong ID", tx.ID)
+            }
+            if tx.IsCoinbase() {
+                    coinbases++
+                    continue
+            }
+            for _, vin := range tx.Vin {
+                    outpoint := fmt.Sprintf("%x:%d", vin.Txid, vin.Vout)
+                    if spent[outpoint] {
+                            return rejectBlock(blk, RejectDoubleSpend, "output %s is spent twice", outpoint)
+                    }
+                    spent[outpoint] = true
+            }
+    }
+    if coinbases != 1 {
+            return rejectBlock(blk, RejectBadCoinbase, "block has %d coinbase transactions", coinbases)
+    }
+
+    return nil
+}
+
+// checkBlockContext checks the block against its parent, which must already be stored
+func checkBlockContext(dbTx *bolt.Tx, block *block.Block) error {
+    if len(block.PrevBlockHash) == 0 {
+            return rejectBlock(block, RejectUnknownParent, "block has no parent")
+    }
+    if dbTx.Bucket([]byte(blocksBucket)).Get(block.PrevBlockHash) == nil {
+            return rejectBlock(block, RejectUnknownParent, "parent %x is not known", block.PrevBlockHash)
+    }
+    parent := getBlockTx(dbTx, block.PrevBlockHash)
+    if block.Height != parent.Height+1 {
+            return rejectBlock(block, RejectBadHeight, "height %d doesn't follow parent height %d", block.Height, parent.Height)
+    }
+    if bits := nextBits(dbTx, parent); block.Bits != bits {
+            return rejectBlock(block, RejectBadDifficulty, "bits %08x don't match the expected %08x", block.Bits, bits)
+    }
+    if median := medianTimePast(dbTx, parent); block.Timestamp <= median {
+            return rejectBlock(block, RejectTimeTooOld, "timestamp %d is not past the median time %d of the blocks before it", block.Timestamp, median)
+    }
+
+    return checkFutureTime(block)
+}
+
+// checkFutureTime rejects blocks from too far in the future. They may become valid
+// as our clock catches up, so they're not a sign of a misbehaving peer.
+func checkFutureTime(blk *block.Block) error {
+    if limit := time.Now().Unix() + block.MaxFutureBlockTime; blk.Timestamp > limit {
+            return rejectBlock(blk, RejectTimeTooNew, "timestamp %d is more than %d seconds ahead of our clock", blk.Timestamp, block.MaxFutureBlockTime)
+    }
+
+    return nil
+}
+
+// checkOrphan checks a block whose parent isn't known, before it's kept in memory.
+// Its bits can't be compared with what its branch expects, but they can't be easier
+// than our tip's eased by every retarget between the two heights, so an orphan costs
+// about as much work as a block on our chain would.
+func checkOrphan(dbTx *bolt.Tx, blk *block.Block) error {
+    tip := getBlockTx(dbTx, getTipTx(dbTx))
+    low, high := tip.Height, blk.Height
+    if low > high {
+            low, high = high, low
+    }
+    retargets := high/block.RetargetInterval - low/block.RetargetInterval
+    if limit := block.EasiestTarget(tip.Bits, retargets); block.CompactToBig(blk.Bits).Cmp(limit) > 0 {
+            return rejectBlock(blk, RejectBadDifficulty, "bits %08x are easier than any chain %d blocks from our tip allows", blk.Bits, high-low)
+    }
+
+    return checkFutureTime(blk)
+}
+
+// checkTransactions validates the block's transactions against the UTXO set it is
+// about to be connected to. Transactions may spend outputs created earlier in the
+// block, and the coinbase may not claim more than the subsidy plus the fees.
+func checkTransactions(dbTx *bolt.Tx, block *block.Block) error {
+    created := make(map[string][]transaction.TXOutput) // outputs of the block's own transactions
+    var coinbase *transaction.Transaction
+    fees := 0
+
+    for _, tx := range block.Transactions {
+            if tx.IsCoinbase() {
+                    coinbase = tx
+                    created[hex.EncodeToString(tx.ID)] = tx.Vout
+                    continue
+            }
+
+            fee, err := checkTransaction(dbTx, tx, block.Hash, created)
+            if err != nil {
+                    return rejectBlock(block, err.(*TxError).Reason, "%s", err)
+            }
+            var ok bool
+            if fees, ok = addValue(fees, fee); !ok {
+                    return rejectBlock(block, RejectBadTransaction, "fees are out of range")
+            }
+            created[hex.EncodeToString(tx.ID)] = tx.Vout
+    }
+
+    reward := 0
+    for _, out := range coinbase.Vout {
+            var ok bool
+            if reward, ok = addValue(reward, out.Value); !ok {
+                    return rejectBlock(block, RejectBadCoinbaseValue, "coinbase output of %d is out of range", out.Value)
+            }
+    }
+    allowed, ok := addValue(fees, transaction.GetBlockSubsidy(block.Height))
+    if !ok || reward > allowed {
+            return rejectBlock(block, RejectBadCoinbaseValue, "coinbase pays %d, allowed %d", reward, allowed)
+    }
+
+    return nil
+}
+
+// checkTransaction validates a non-coinbase transaction against the outputs it spends,
+// looking them up in created first and in the UTXO set otherwise. Every input must
+// unlock its output with a valid signature and the outputs can't be worth more than
+// the inputs. Previous transactions are searched from the block with hash from backwards.
+// It returns the fee the transaction pays.
+func checkTransaction(dbTx *bolt.Tx, tx *transaction.Transaction, from []byte, created map[string][]transaction.TXOutput) (int, error) {
+    prevTXs := make(map[string]transaction.Transaction)
+    inputs := 0
+
+    for _, vin := range tx.Vin {
+            var prevOut *transaction.TXOutput
+            if outs, ok := created[hex.EncodeToString(vin.Txid)]; ok {
+                    if vin.Vout >= 0 && vin.Vout < len(outs) {
+                            prevOut = &outs[vin.Vout]
+                    }
+            } else if entry, ok := utxo.FetchEntry(dbTx, vin.Txid, vin.Vout); ok {
+                    prevOut = &entry.TXOutput
+            }
+            if prevOut == nil {
+                    return 0, rejectTx(tx, RejectMissingInput, "spends unknown output %x:%d", vin.Txid, vin.Vout)
+            }
+            if !vin.UsesKey(prevOut.PubKeyHash) {
+                    return 0, rejectTx(tx, RejectBadTransaction, "can't unlock output %x:%d", vin.Txid, vin.Vout)
+            }
+            var ok bool
+            if inputs, ok = addValue(inputs, prevOut.Value); !ok {
+                    return 0, rejectTx(tx, RejectBadTransaction, "inputs are out of range")
+            }
+
+            prevTx, err := findTransactionTx(dbTx, from, vin.Txid)
+            if err != nil || vin.Vout >= len(prevTx.Vout) {
+                    return 0, rejectTx(tx, RejectMissingInput, "spends unknown transaction %x", vin.Txid)
+            }
+            prevTXs[hex.EncodeToString(prevTx.ID)] = prevTx
+    }
+
+    outputs := 0
+    for _, out := range tx.Vout {
+            var ok bool
+            if outputs, ok = addValue(outputs, out.Value); !ok {
+                    return 0, rejectTx(tx, RejectBadTransaction, "has an output of %d, out of range", out.Value)
+            }
+    }
+    if outputs > inputs {
+            return 0, rejectTx(tx, RejectBadTransaction, "spends %d but has only %d", outputs, inputs)
+    }
+    if err := tx.Verify(prevTXs); err != nil {
+            return 0, rejectTx(tx, RejectBadTransaction, "%s", err)
+    }
+
+    return inputs - outputs, nil
+}
+
+// addValue adds an amount of coins to total. It fails if the amount is negative or if
+// either of them is above the max supply, which also keeps the sum from overflowing.
+func addValue(total, value int) (int, bool) {
+    max := transaction.MaxSupply()
+    if value < 0 || value > max || total > max-value {
+            return total, false
+    }
+
+    return total + value, true
+}
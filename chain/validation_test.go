@@ -0,0 +1,120 @@
+package chain
+
+import (
+    "math"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestBlockValidation(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    address := string(miner.GetAddress())
+    to := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, address, dbFile)
+    defer bc.db.Close()
+    first := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(address, "", 1, 0)})
+    tip := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(address, "", 2, 0)})
+
+    // the blocks are mined on the tip, with a valid PoW unless the test breaks it
+    coinbase := func() *transaction.Transaction {
+            return transaction.NewCoinbaseTX(address, "", tip.Height+1, 0)
+    }
+    next := func(txs ...*transaction.Transaction) *block.Block {
+            return block.NewBlockAt(txs, tip.Hash, tip.Height+1, tip.Bits, tip.Timestamp+1)
+    }
+
+    tests := []struct {
+            name   string
+            block  func() *block.Block
+            reason RejectReason
+    }{
+            {"bad PoW", func() *block.Block {
+                    b := next(coinbase())
+                    b.Nonce++
+                    return b
+            }, RejectBadPoW},
+            {"wrong Merkle root", func() *block.Block {
+                    b := next(coinbase())
+                    b.Transactions = []*transaction.Transaction{coinbase()} // the header and its PoW stay valid
+                    return b
+            }, RejectBadMerkleRoot},
+            {"wrong height", func() *block.Block {
+                    return block.NewBlockAt([]*transaction.Transaction{coinbase()}, tip.Hash, tip.Height+2, tip.Bits, tip.Timestamp+1)
+            }, RejectBadHeight},
+            {"timestamp not past the median time", func() *block.Block {
+                    // the median of genesis, first and tip
+                    return block.NewBlockAt([]*transaction.Transaction{coinbase()}, tip.Hash, tip.Height+1, tip.Bits, first.Timestamp)
+            }, RejectTimeTooOld},
+            {"timestamp too far in the future", func() *block.Block {
+                    return block.NewBlockAt([]*transaction.Transaction{coinbase()}, tip.Hash, tip.Height+1, tip.Bits, time.Now().Unix()+block.MaxFutureBlockTime+60)
+            }, RejectTimeTooNew},
+            {"two coinbases", func() *block.Block {
+                    return next(coinbase(), coinbase())
+            }, RejectBadCoinbase},
+            {"no coinbase", func() *block.Block {
+                    return next(spendCoinbase(bc, miner, first, to, 5, 1))
+            }, RejectBadCoinbase},
+            {"wrong transaction ID", func() *block.Block {
+                    tx := spendCoinbase(bc, miner, first, to, 5, 1)
+                    tx.ID[0] ^= 1
+                    return next(coinbase(), tx)
+            }, RejectBadTxID},
+            {"double spend in the block", func() *block.Block {
+                    return next(coinbase(), spendCoinbase(bc, miner, first, to, 5, 1), spendCoinbase(bc, miner, first, to, 4, 1))
+            }, RejectDoubleSpend},
+            {"spending more than the inputs", func() *block.Block {
+                    return next(coinbase(), spendCoinbase(bc, miner, first, to, 5, -1))
+            }, RejectBadTransaction},
+            {"spending a missing output", func() *block.Block {
+                    tx := spendCoinbase(bc, miner, first, to, 5, 1)
+                    tx.Vin[0].Vout = 1
+                    tx.ID = tx.Hash()
+                    return next(coinbase(), tx)
+            }, RejectMissingInput},
+            {"overflowing outputs", func() *block.Block {
+                    // they add up to -2 if the sum isn't checked, which is less than the inputs
+                    tx := spendCoinbase(bc, miner, first, to, 5, 1)
+                    tx.Vout[0].Value = math.MaxInt64
+                    tx.Vout[1].Value = math.MaxInt64
+                    assert.Nil(t, bc.SignTransaction(tx, miner.PrivateKey))
+                    tx.ID = tx.Hash()
+                    return next(coinbase(), tx)
+            }, RejectBadTransaction},
+            {"coinbase with a negative output", func() *block.Block {
+                    // the outputs add up to the subsidy, one of them makes the coins out of nothing
+                    cb := coinbase()
+                    cb.Vout[0].Value += 100
+                    cb.Vout = append(cb.Vout, *transaction.NewTXOutput(-100, address))
+                    cb.ID = cb.Hash()
+                    return next(cb)
+            }, RejectBadCoinbaseValue},
+            {"coinbase above the subsidy", func() *block.Block {
+                    return next(transaction.NewCoinbaseTX(address, "", tip.Height+1, 1))
+            }, RejectBadCoinbaseValue},
+            {"coinbase above the subsidy and the fees", func() *block.Block {
+                    return next(transaction.NewCoinbaseTX(address, "", tip.Height+1, 2), spendCoinbase(bc, miner, first, to, 5, 1))
+            }, RejectBadCoinbaseValue},
+    }
+
+    for _, test := range tests {
+            _, _, err := bc.AddBlock(test.block())
+            if assert.IsType(t, &BlockError{}, err, test.name) {
+                    assert.Equal(t, test.reason, err.(*BlockError).Reason, test.name)
+            }
+    }
+    assert.Equal(t, tip.Hash, bc.Tip(), "Rejected blocks leave the chain as it was")
+
+    // the miner may claim the fees on top of the subsidy, nothing more
+    connected, _, err := bc.AddBlock(next(transaction.NewCoinbaseTX(address, "", tip.Height+1, 1), spendCoinbase(bc, miner, first, to, 5, 1)))
+    assert.Nil(t, err)
+    assert.Len(t, connected, 1)
+}
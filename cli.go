@@ -1,146 +0,0 @@
-package main
-
-import (
-    "flag"
-    "fmt"
-    "log"
-    "os"
-)
-
-type CLI struct {}
-
-func (cli *CLI) printUsage() {
-    fmt.Println("Usage:")
-    fmt.Println("  printchain - print all the blocks of the blockchain")
-    fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
-    fmt.Println("  getbalance -address ADDRESS - Get balance of ADDRESS")
-    fmt.Println("  send -from FROM -to TO -amount AMOUNT - Send AMOUNT of coins from FROM address to TO")
-    fmt.Println("  createwallet - Generates a new key-pair and saves it into the wallet file")
-    fmt.Println("  listaddresses - Lists all addresses from the wallet file")
-    fmt.Println("  reindexutxo - Rebuilds the UTXO set")
-    fmt.Println("  startnode -miner ADDRESS - Start a node with ID specified in NODE_ID env. var. -miner enables mining")
-}
-
-func (cli *CLI) validateArgs() { 
-    if len(os.Args) < 2 {   // must hava more than one parament
-            cli.printUsage()
-            os.Exit(1)
-    }
-}
-
-// Run parses command line arguments and processes commands
-func (cli *CLI) Run() {
-    cli.validateArgs()
-    nodeID := os.Getenv("NODE_ID")
-    if nodeID == "" {
-            fmt.Printf("NODE_ID env. var is not set!")
-            os.Exit(1)
-    }
-
-    createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
-    printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
-    getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
-    sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
-    createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
-    listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
-    reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError) 
-    startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
-
-    createBlockchainAddress := createBlockchainCmd.String("address", "", "he address to send genesis block reward to")
-    getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
-    sendFrom := sendCmd.String("from", "", "Source wallet address")
-    sendTo := sendCmd.String("to", "", "Destination wallet address")
-    sendAmount := sendCmd.Int("amount", 0, "Amount to send")
-    sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
-    startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
-
-    //check the command provided by user and parse related flag subcommand.
-    switch os.Args[1] {
-    case "createblockchain":
-            err := createBlockchainCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "printchain":
-            err := printChainCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "getbalance":
-            err := getBalanceCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "send" :
-            err := sendCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "createwallet":
-            err := createWalletCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "listaddresses":
-            err := listAddressesCmd.Parse(os.Args[2:])
-            if err != nil {
-                    log.Panic(err)
-            }
-    case "reindexutxo":
-            err := reindexUTXOCmd.Parse(os.Args[2:])
-            if err != nil {
-                        log.Panic(err)
-            }
-    case "startnode":
-            err := startNodeCmd.Parse(os.Args[2:])
-            if err != nil {
-                        log.Panic(err)
-            }
-    default:
-            cli.printUsage()
-            os.Exit(1)
-    }
-
-    if createBlockchainCmd.Parsed() {
-            if *createBlockchainAddress == "" {
-                    createBlockchainCmd.Usage()
-                    os.Exit(1)
-            }
-            cli.createBlockchain(*createBlockchainAddress, nodeID)
-    }
-    if printChainCmd.Parsed() {
-            cli.printChain(nodeID)
-    }
-    if getBalanceCmd.Parsed() {
-            if *getBalanceAddress == "" {
-                    getBalanceCmd.Usage()
-                    os.Exit(1)
-            }
-            cli.getBalance(*getBalanceAddress, nodeID)
-    }
-    if sendCmd.Parsed() {
-            if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 {
-                    sendCmd.Usage()
-                    os.Exit(1)
-            }
-            // here pay attenion 
-            cli.send(*sendFrom, *sendTo, *sendAmount, nodeID, *sendMine)
-    }
-    if createWalletCmd.Parsed() {
-            cli.createWallet(nodeID)
-    }
-    if listAddressesCmd.Parsed() {
-            cli.listAddresses(nodeID)
-    }
-    if reindexUTXOCmd.Parsed() {
-            cli.reindexUTXO(nodeID)
-    }
-    if startNodeCmd.Parsed() {
-            nodeID := os.Getenv("NODE_ID")
-            if nodeID == "" {
-                    startNodeCmd.Usage()
-                    os.Exit(1)
-            }
-            cli.startNode(nodeID, *startNodeMiner)
-    }
-}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+    "flag"
+    "fmt"
+    "log"
+    "os"
+
+    "blockchain_go/chain"
+)
+
+type CLI struct {}
+
+func (cli *CLI) printUsage() {
+    fmt.Println("Usage:")
+    fmt.Println("  printchain [-from HEIGHT] [-to HEIGHT] [-height HEIGHT | -hash HASH] [-rpc HOST:PORT] - print all the blocks of the blockchain, the main chain blocks in a height range or a single block")
+    fmt.Println("  createblockchain -address ADDRESS - Create a blockchain and send genesis block reward to ADDRESS")
+    fmt.Println("  getbalance -address ADDRESS [-rpc HOST:PORT] - Get balance of ADDRESS")
+    fmt.Println("  history -address ADDRESS [-rpc HOST:PORT] - List the transactions paying to or spending from ADDRESS, needs the address index")
+    fmt.Println("  gettransaction -id TXID [-rpc HOST:PORT] - Print a transaction with the block confirming it")
+    fmt.Println("  send -from FROM -to TO -amount AMOUNT [-fee FEE | -feerate RATE] [-mine | -peers HOST:PORT,... | -config FILE | -rpc HOST:PORT] - Send AMOUNT of coins from FROM address to TO, paying FEE or RATE per kB to the miner")
+    fmt.Println("  createwallet [-rpc HOST:PORT] - Generates a new key-pair and saves it into the wallet file")
+    fmt.Println("  listaddresses [-rpc HOST:PORT] - Lists all addresses from the wallet file")
+    fmt.Println("  reindexutxo [-addrindex] - Rebuilds the UTXO set, -addrindex also builds the address index and keeps it from then on")
+    fmt.Println("  getsupply [-rpc HOST:PORT] - Print the circulating and maximum supply of coins")
+    fmt.Println("  startnode [-config FILE] [-listen HOST:PORT] [-advertise HOST:PORT] [-peers HOST:PORT,...] [-miner ADDRESS] [-rpc HOST:PORT] [-explorer HOST:PORT] - Start a node with ID specified in NODE_ID env. var. -miner enables mining, -rpc serves JSON-RPC, -explorer the read-only explorer API")
+    fmt.Println("With -rpc HOST:PORT the other commands ask the running node's JSON-RPC server instead of opening its files")
+    fmt.Println("The CHAIN_PARAMS env. var may name a JSON file with the network's consensus parameters, the same for every node")
+}
+
+// openBlockchain opens the blockchain of the node, the commands reading it can't go on without one
+func openBlockchain(nodeID string) *chain.Blockchain {
+    bc, err := chain.NewBlockchain(nodeID)
+    if err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+    }
+    return bc
+}
+
+func (cli *CLI) validateArgs() { 
+    if len(os.Args) < 2 {   // must hava more than one parament
+            cli.printUsage()
+            os.Exit(1)
+    }
+}
+
+// Run parses command line arguments and processes commands
+func (cli *CLI) Run() {
+    cli.validateArgs()
+    nodeID := os.Getenv("NODE_ID")
+    if nodeID == "" {
+            fmt.Printf("NODE_ID env. var is not set!")
+            os.Exit(1)
+    }
+    // a network with other consensus parameters than the defaults has them in a file
+    if paramsFile := os.Getenv("CHAIN_PARAMS"); paramsFile != "" {
+            err := chain.LoadParams(paramsFile)
+            if err != nil {
+                    fmt.Printf("Can't load the chain parameters from %s: %s\n", paramsFile, err)
+                    os.Exit(1)
+            }
+    }
+
+    createBlockchainCmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
+    printChainCmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+    getBalanceCmd := flag.NewFlagSet("getbalance", flag.ExitOnError)
+    historyCmd := flag.NewFlagSet("history", flag.ExitOnError)
+    getTransactionCmd := flag.NewFlagSet("gettransaction", flag.ExitOnError)
+    sendCmd := flag.NewFlagSet("send", flag.ExitOnError)
+    createWalletCmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+    listAddressesCmd := flag.NewFlagSet("listaddresses", flag.ExitOnError)
+    reindexUTXOCmd := flag.NewFlagSet("reindexutxo", flag.ExitOnError) 
+    getSupplyCmd := flag.NewFlagSet("getsupply", flag.ExitOnError)
+    startNodeCmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+
+    createBlockchainAddress := createBlockchainCmd.String("address", "", "he address to send genesis block reward to")
+    printChainFrom := printChainCmd.Int("from", -1, "First height to print, going forward")
+    printChainTo := printChainCmd.Int("to", -1, "Last height to print, the tip by default")
+    printChainHeight := printChainCmd.Int("height", -1, "Height of the single main chain block to print")
+    printChainHash := printChainCmd.String("hash", "", "Hash of the single block to print")
+    printChainRPC := printChainCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to ask")
+    getBalanceAddress := getBalanceCmd.String("address", "", "The address to get balance for")
+    getBalanceRPC := getBalanceCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to ask")
+    historyAddress := historyCmd.String("address", "", "The address to list the transactions of")
+    historyRPC := historyCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to ask")
+    getTransactionID := getTransactionCmd.String("id", "", "Hex ID of the transaction")
+    getTransactionRPC := getTransactionCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to ask")
+    reindexAddrIndex := reindexUTXOCmd.Bool("addrindex", false, "Build the address index too")
+    sendFrom := sendCmd.String("from", "", "Source wallet address")
+    sendTo := sendCmd.String("to", "", "Destination wallet address")
+    sendAmount := sendCmd.Int("amount", 0, "Amount to send")
+    sendFee := sendCmd.Int("fee", 0, "Fee paid to the miner")
+    sendFeeRate := sendCmd.Int("feerate", 0, "Fee paid to the miner per started kB of the transaction, overrides -fee")
+    sendMine := sendCmd.Bool("mine", false, "Mine immediately on the same node")
+    sendPeers := sendCmd.String("peers", "", "Comma separated HOST:PORT of the nodes to send the transaction to")
+    sendConfig := sendCmd.String("config", "", "Config file whose peers the transaction is sent to")
+    sendRPC := sendCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to send the transaction through")
+    createWalletRPC := createWalletCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to create the key on")
+    listAddressesRPC := listAddressesCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node whose wallet file to list")
+    getSupplyRPC := getSupplyCmd.String("rpc", "", "HOST:PORT of the JSON-RPC server of the running node to ask")
+    startNodeMiner := startNodeCmd.String("miner", "", "Enable mining mode and send reward to ADDRESS")
+    startNodeConfig := startNodeCmd.String("config", "", "JSON config file with listen, advertise, peers and miner")
+    startNodeListen := startNodeCmd.String("listen", "", "HOST:PORT to accept peers on, localhost:NODE_ID by default")
+    startNodeAdvertise := startNodeCmd.String("advertise", "", "HOST:PORT other nodes reach this one at, the listening address by default")
+    startNodePeers := startNodeCmd.String("peers", "", "Comma separated HOST:PORT of bootstrap peers")
+    startNodeRPC := startNodeCmd.String("rpc", "", "HOST:PORT to serve JSON-RPC on, off by default")
+    startNodeExplorer := startNodeCmd.String("explorer", "", "HOST:PORT to serve the explorer API and feed on, off by default")
+
+    //check the command provided by user and parse related flag subcommand.
+    switch os.Args[1] {
+    case "createblockchain":
+            err := createBlockchainCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "printchain":
+            err := printChainCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "getbalance":
+            err := getBalanceCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "history":
+            err := historyCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "gettransaction":
+            err := getTransactionCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "send" :
+            err := sendCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "createwallet":
+            err := createWalletCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "listaddresses":
+            err := listAddressesCmd.Parse(os.Args[2:])
+            if err != nil {
+                    log.Panic(err)
+            }
+    case "reindexutxo":
+            err := reindexUTXOCmd.Parse(os.Args[2:])
+            if err != nil {
+                        log.Panic(err)
+            }
+    case "getsupply":
+            err := getSupplyCmd.Parse(os.Args[2:])
+            if err != nil {
+                        log.Panic(err)
+            }
+    case "startnode":
+            err := startNodeCmd.Parse(os.Args[2:])
+            if err != nil {
+                        log.Panic(err)
+            }
+    default:
+            cli.printUsage()
+            os.Exit(1)
+    }
+
+    if createBlockchainCmd.Parsed() {
+            if *createBlockchainAddress == "" {
+                    createBlockchainCmd.Usage()
+                    os.Exit(1)
+            }
+            cli.createBlockchain(*createBlockchainAddress, nodeID)
+    }
+    if printChainCmd.Parsed() {
+            if *printChainHeight >= 0 {
+                    *printChainFrom, *printChainTo = *printChainHeight, *printChainHeight
+            }
+            cli.printChain(nodeID, *printChainFrom, *printChainTo, *printChainHash, *printChainRPC)
+    }
+    if getBalanceCmd.Parsed() {
+            if *getBalanceAddress == "" {
+                    getBalanceCmd.Usage()
+                    os.Exit(1)
+            }
+            cli.getBalance(*getBalanceAddress, nodeID, *getBalanceRPC)
+    }
+    if historyCmd.Parsed() {
+            if *historyAddress == "" {
+                    historyCmd.Usage()
+                    os.Exit(1)
+            }
+            cli.history(*historyAddress, nodeID, *historyRPC)
+    }
+    if getTransactionCmd.Parsed() {
+            if *getTransactionID == "" {
+                    getTransactionCmd.Usage()
+                    os.Exit(1)
+            }
+            cli.getTransaction(*getTransactionID, nodeID, *getTransactionRPC)
+    }
+    if sendCmd.Parsed() {
+            if *sendFrom == "" || *sendTo == "" || *sendAmount <= 0 || *sendFee < 0 || *sendFeeRate < 0 {
+                    sendCmd.Usage()
+                    os.Exit(1)
+            }
+            // here pay attenion 
+            if *sendRPC != "" {
+                    cli.sendRPC(*sendFrom, *sendTo, *sendAmount, *sendFee, *sendFeeRate, nodeID, *sendRPC)
+            } else {
+                    config := nodeConfig(*sendConfig, "", "", *sendPeers, "", "", "", nodeID)
+                    cli.send(*sendFrom, *sendTo, *sendAmount, *sendFee, *sendFeeRate, nodeID, *sendMine, config.Peers)
+            }
+    }
+    if createWalletCmd.Parsed() {
+            cli.createWallet(nodeID, *createWalletRPC)
+    }
+    if listAddressesCmd.Parsed() {
+            cli.listAddresses(nodeID, *listAddressesRPC)
+    }
+    if reindexUTXOCmd.Parsed() {
+            cli.reindexUTXO(nodeID, *reindexAddrIndex)
+    }
+    if getSupplyCmd.Parsed() {
+            cli.getSupply(nodeID, *getSupplyRPC)
+    }
+    if startNodeCmd.Parsed() {
+            nodeID := os.Getenv("NODE_ID")
+            if nodeID == "" {
+                    startNodeCmd.Usage()
+                    os.Exit(1)
+            }
+            config := nodeConfig(*startNodeConfig, *startNodeListen, *startNodeAdvertise, *startNodePeers, *startNodeMiner, *startNodeRPC, *startNodeExplorer, nodeID)
+            cli.startNode(nodeID, config)
+    }
+}
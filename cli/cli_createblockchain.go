@@ -0,0 +1,26 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+        "os"
+
+        "blockchain_go/chain"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) createBlockchain(address string, nodeID string) {
+    if !wallet.ValidateAddress(address) {
+            log.Panic("ERROR: Address is not valid")
+    }
+    bc, err := chain.CreateBlockchain(address, nodeID)
+    if err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+    }
+    defer bc.Close()  // fix the bug, database not open
+
+    fmt.Println("Done!")
+}
+
+
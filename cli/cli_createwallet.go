@@ -0,0 +1,33 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+        "os"
+
+        "blockchain_go/rpc"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) createWallet(nodeID, rpcAddr string){
+    if rpcAddr != "" { // the node writes its wallet file itself, so the key isn't lost to a concurrent write
+            address, err := rpc.NewClient(rpcAddr).CreateWallet()
+            if err != nil {
+                    log.Panic(err)
+            }
+            fmt.Printf("Your new address: %s\n", address)
+            return
+    }
+    wallets, err := wallet.NewWallets(nodeID)
+    if err != nil && !os.IsNotExist(err) { // the first wallet creates the file
+            log.Panic(err)
+    }
+    address := wallets.CreateWallet()
+    err = wallets.SaveToFile(nodeID)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    fmt.Printf("Your new address: %s\n", address)
+}
+
@@ -0,0 +1,40 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+
+        "blockchain_go/rpc"
+        "blockchain_go/utxo"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) getBalance(address, nodeID, rpcAddr string) {
+    if !wallet.ValidateAddress(address){
+            log.Panic("ERROR: Adderss is not valid")
+    }
+    if rpcAddr != "" {
+            balance, err := rpc.NewClient(rpcAddr).GetBalance(address)
+            if err != nil {
+                    log.Panic(err)
+            }
+            fmt.Printf("Balance of '%s': %d\n", address, balance)
+            return
+    }
+    bc := openBlockchain(nodeID)
+    UXTOSet := utxo.UTXOSet{Blockchain: bc}
+    defer bc.Close()
+
+    balance := 0
+    pubKeyHash := wallet.Base58Decode([]byte(address))
+    pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+    UTXOs := UXTOSet.FindUTXO(pubKeyHash)
+    for _, out := range UTXOs {
+            balance += out.Value
+    }
+
+    fmt.Printf("Balance of '%s': %d\n", address, balance)
+}
+
+
+
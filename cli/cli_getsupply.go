@@ -0,0 +1,32 @@
+package cli
+
+import (
+    "fmt"
+    "log"
+
+    "blockchain_go/rpc"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+)
+
+func (cli *CLI) getSupply(nodeID, rpcAddr string) {
+    var info rpc.SupplyInfo
+    if rpcAddr != "" {
+            result, err := rpc.NewClient(rpcAddr).GetSupply()
+            if err != nil {
+                    log.Panic(err)
+            }
+            info = *result
+    } else {
+            bc := openBlockchain(nodeID)
+            UTXOSet := utxo.UTXOSet{Blockchain: bc}
+            height := bc.GetBestHeight()
+            info = rpc.SupplyInfo{Height: height, Circulating: UTXOSet.TotalValue(), Max: transaction.MaxSupply(), NextSubsidy: transaction.GetBlockSubsidy(height + 1)}
+            bc.Close()
+    }
+
+    fmt.Printf("Height: %d\n", info.Height)
+    fmt.Printf("Circulating supply: %d\n", info.Circulating)
+    fmt.Printf("Maximum supply: %d\n", info.Max)
+    fmt.Printf("Next block subsidy: %d\n", info.NextSubsidy)
+}
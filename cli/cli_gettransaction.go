@@ -0,0 +1,43 @@
+package cli
+
+import (
+        "encoding/hex"
+        "fmt"
+        "log"
+
+        "blockchain_go/rpc"
+)
+
+func (cli *CLI) getTransaction(id, nodeID, rpcAddr string) {
+    txid, err := hex.DecodeString(id)
+    if err != nil {
+            log.Panic("ERROR: Transaction ID is not valid")
+    }
+    if rpcAddr != "" {
+            tx, result, err := rpc.NewClient(rpcAddr).GetTransaction(txid)
+            if err != nil {
+                    log.Panic(err)
+            }
+            fmt.Println(tx)
+            if result.BlockHeight == nil {
+                    fmt.Println("In the mempool, not confirmed yet")
+                    return
+            }
+            fmt.Printf("Block: %s\n", result.BlockHash)
+            fmt.Printf("Height: %d\n", *result.BlockHeight)
+            fmt.Printf("Confirmations: %d\n", result.Confirmations)
+            return
+    }
+    bc := openBlockchain(nodeID)
+    defer bc.Close()
+
+    tx, block, err := bc.GetTransaction(txid)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    fmt.Println(tx)
+    fmt.Printf("Block: %x\n", block.Hash)
+    fmt.Printf("Height: %d\n", block.Height)
+    fmt.Printf("Confirmations: %d\n", bc.GetBestHeight()-block.Height+1)
+}
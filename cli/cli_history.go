@@ -0,0 +1,42 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+
+        "blockchain_go/rpc"
+        "blockchain_go/utxo"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) history(address, nodeID, rpcAddr string) {
+    if !wallet.ValidateAddress(address) {
+            log.Panic("ERROR: Address is not valid")
+    }
+    if rpcAddr != "" {
+            history, err := rpc.NewClient(rpcAddr).GetAddressHistory(address)
+            if err != nil {
+                    log.Panic(err)
+            }
+            for _, tx := range history {
+                    fmt.Printf("%s at height %d\n", tx.Txid, tx.Height)
+            }
+            fmt.Printf("%d transactions\n", len(history))
+            return
+    }
+    bc := openBlockchain(nodeID)
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+    defer bc.Close()
+
+    pubKeyHash := wallet.Base58Decode([]byte(address))
+    pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+    history, ok := UTXOSet.AddressHistory(pubKeyHash)
+    if !ok {
+            log.Panic("ERROR: No address index, build it with reindexutxo -addrindex")
+    }
+
+    for _, tx := range history {
+            fmt.Printf("%x at height %d\n", tx.Txid, tx.Height)
+    }
+    fmt.Printf("%d transactions\n", len(history))
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+
+        "blockchain_go/rpc"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) listAddresses(nodeID, rpcAddr string){
+    var addresses []string
+    if rpcAddr != "" {
+        var err error
+        addresses, err = rpc.NewClient(rpcAddr).ListAddresses()
+        if err != nil {
+            log.Panic(err)
+        }
+    } else {
+        wallets, err := wallet.NewWallets(nodeID)
+        if err != nil {
+            log.Panic(err)
+        }
+        addresses = wallets.GetAddresses()
+    }
+
+    for _, address := range addresses {
+        fmt.Println(address)
+    }
+}
+
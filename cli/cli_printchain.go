@@ -0,0 +1,132 @@
+package cli
+
+import (
+        "encoding/hex"
+        "fmt"
+        "log"
+        "strconv"
+
+        "blockchain_go/block"
+        "blockchain_go/chain"
+        "blockchain_go/rpc"
+)
+
+// printChain prints the whole chain from the tip back to genesis, or the main chain blocks
+// from height from to height to going forward when either is given (>= 0).
+// A single block is printed with from = to, or by its hash.
+func (cli *CLI) printChain(nodeID string, from, to int, hash, rpcAddr string) {
+    if rpcAddr != "" {
+            printChainRPC(rpc.NewClient(rpcAddr), from, to, hash)
+            return
+    }
+    bc := openBlockchain(nodeID)
+    defer bc.Close()
+
+    if hash != "" {
+            blockHash, err := hex.DecodeString(hash)
+            if err != nil {
+                    log.Panic("ERROR: Block hash is not valid")
+            }
+            block, err := bc.GetBlock(blockHash)
+            if err != nil {
+                    log.Panic(err)
+            }
+            printBlock(&block)
+            return
+    }
+    if from >= 0 && from == to {
+            block, err := bc.GetBlockByHeight(from)
+            if err != nil {
+                    log.Panic(err)
+            }
+            printBlock(&block)
+            return
+    }
+    if from >= 0 || to >= 0 {
+            if to < 0 {
+                    to = bc.GetBestHeight()
+            }
+            err := bc.RangeBlocks(from, to, func(block *block.Block) bool {
+                    printBlock(block)
+                    return true
+            })
+            if err != nil {
+                    log.Panic(err)
+            }
+            return
+    }
+
+    bci := bc.Iterator()
+    for {
+            block, err := bci.Next()  // print the last block to geneis block
+            if err == chain.ErrEndOfChain {
+                    break
+            }
+            if err != nil {
+                    log.Panic(err)
+            }
+            printBlock(block)
+        }
+}
+
+// printChainRPC is printChain with the blocks coming from the node's JSON-RPC server, one at a time
+func printChainRPC(c *rpc.Client, from, to int, hash string) {
+    printHash := func(blockHash []byte) *block.Block {
+            block, _, err := c.GetBlock(blockHash)
+            if err != nil {
+                    log.Panic(err)
+            }
+            printBlock(block)
+            return block
+    }
+
+    if hash != "" {
+            blockHash, err := hex.DecodeString(hash)
+            if err != nil {
+                    log.Panic("ERROR: Block hash is not valid")
+            }
+            printHash(blockHash)
+            return
+    }
+    if from >= 0 || to >= 0 {
+            if to < 0 {
+                    best, err := c.GetBlockCount()
+                    if err != nil {
+                            log.Panic(err)
+                    }
+                    to = best
+            }
+            if from < 0 {
+                    from = 0
+            }
+            for height := from; height <= to; height++ {
+                    blockHash, err := c.GetBlockHash(height)
+                    if err != nil {
+                            log.Panic(err)
+                    }
+                    printHash(blockHash)
+            }
+            return
+    }
+
+    blockHash, err := c.GetBestBlockHash()
+    if err != nil {
+            log.Panic(err)
+    }
+    for len(blockHash) > 0 { // back to genesis
+            blockHash = printHash(blockHash).PrevBlockHash
+    }
+}
+
+func printBlock(b *block.Block) {
+    fmt.Printf("============ Block %x ============\n", b.Hash) 
+    fmt.Printf("Height: %d\n", b.Height)
+    fmt.Printf("Prev. hash: %x\n", b.PrevBlockHash)
+    fmt.Printf("Merkle root: %x\n", b.MerkleRoot)
+    pow := block.NewProofOfWork(b)
+    fmt.Printf("PoW: %s\n", strconv.FormatBool(pow.Validate()))
+    for _, tx := range b.Transactions {
+            fmt.Println(tx)
+    }
+    fmt.Printf("\n\n")
+}
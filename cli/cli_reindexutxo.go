@@ -0,0 +1,25 @@
+package cli
+
+import (
+    "fmt"
+    "log"
+
+    "blockchain_go/utxo"
+)
+
+func (cli *CLI) reindexUTXO(nodeID string, addrIndex bool) {
+    bc := openBlockchain(nodeID)
+    defer bc.Close()
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+    UTXOSet.Reindex() // the address index is rebuilt along if there is one
+    if addrIndex {
+            err := UTXOSet.ReindexAddresses()
+            if err != nil {
+                    log.Panic(err)
+            }
+            fmt.Println("Address index built.")
+    }
+
+    count := UTXOSet.CountTransactions()
+    fmt.Printf("Done! There are %d transactions in the UTXO set.\n", count)
+}
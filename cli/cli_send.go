@@ -0,0 +1,103 @@
+package cli
+
+import (
+        "fmt"
+        "log"
+
+        "blockchain_go/p2p"
+        "blockchain_go/rpc"
+        "blockchain_go/transaction"
+        "blockchain_go/utxo"
+        "blockchain_go/wallet"
+)
+
+func (cli *CLI) send(from, to string, amount, fee, feeRate int, nodeID string, mineNow bool, peers []string) {
+    if !wallet.ValidateAddress(from) {
+            log.Panic("ERROR: Sender address is not valid")
+    }
+    if !wallet.ValidateAddress(to) {
+            log.Panic("ERROR: Recipient address is not valid")
+    }
+    if !mineNow && len(peers) == 0 {
+            log.Panic("ERROR: No peers to send the transaction to, use -peers or -config")
+    }
+
+    bc := openBlockchain(nodeID)
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+    defer bc.Close()
+
+    wallets, err := wallet.NewWallets(nodeID)
+    if err != nil {
+            log.Panic(err)
+    }
+    wallet, err := wallets.GetWallet(from)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    var tx *transaction.Transaction
+    if feeRate > 0 {
+            tx, err = utxo.NewUTXOTransactionWithFeeRate(wallet, to, amount, feeRate, &UTXOSet)
+    } else {
+            tx, err = utxo.NewUTXOTransaction(wallet, to, amount, fee, &UTXOSet)
+    }
+    if err != nil {
+            log.Panic(err)
+    }
+    if mineNow { 
+            fee, err := UTXOSet.Fee(tx)
+            if err != nil {
+                    log.Panic(err)
+            }
+            coinbase := func(height int) *transaction.Transaction {
+                    return transaction.NewCoinbaseTX(from, "", height, fee)
+            }
+
+            _, _, _, err = bc.MineBlock([]*transaction.Transaction{tx}, coinbase)
+            if err != nil {
+                    log.Panic(err)
+            }
+    }else {
+            n := p2p.NewNode(p2p.Config{Peers: peers}, bc) // doesn't listen, it only hands the transaction over
+            for _, peer := range peers {
+                    n.SendTx(peer, tx)
+            }
+            n.Close() // waits until the transaction is written
+    }
+    fmt.Println("Success!")
+}
+
+// sendRPC signs the transaction with the local wallet and has the running node behind rpcAddr relay it
+func (cli *CLI) sendRPC(from, to string, amount, fee, feeRate int, nodeID, rpcAddr string) {
+    if !wallet.ValidateAddress(from) {
+            log.Panic("ERROR: Sender address is not valid")
+    }
+    if !wallet.ValidateAddress(to) {
+            log.Panic("ERROR: Recipient address is not valid")
+    }
+
+    wallets, err := wallet.NewWallets(nodeID)
+    if err != nil {
+            log.Panic(err)
+    }
+    w, err := wallets.GetWallet(from)
+    if err != nil {
+            log.Panic(err)
+    }
+    client := rpc.NewClient(rpcAddr)
+
+    var tx *transaction.Transaction
+    if feeRate > 0 {
+            tx, err = client.NewTransactionWithFeeRate(w, to, amount, feeRate)
+    } else {
+            tx, err = client.NewTransaction(w, to, amount, fee)
+    }
+    if err != nil {
+            log.Panic(err)
+    }
+    err = client.SendRawTransaction(tx)
+    if err != nil {
+            log.Panic(err)
+    }
+    fmt.Printf("Success! Transaction %x\n", tx.ID)
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+    "fmt"
+    "log"
+    "os"
+
+    "blockchain_go/chain"
+    "blockchain_go/explorer"
+    "blockchain_go/p2p"
+    "blockchain_go/rpc"
+    "blockchain_go/wallet"
+)
+
+func (cli *CLI) startNode(nodeID string, config p2p.Config) {
+    fmt.Printf("Starting node %s on %s\n", nodeID, config.Listen)
+    if len(config.Miner) > 0 {
+            if wallet.ValidateAddress(config.Miner) {
+                    fmt.Println("Mining is on. Address to receive rewards: ", config.Miner)
+            } else {
+                    log.Panic("Wrong miner address!")
+            }
+    }
+    if len(config.Peers) == 0 {
+            fmt.Println("No bootstrap peers, waiting for others to connect")
+    }
+    err := serve(nodeID, config)
+    if err != nil {
+            fmt.Println(err)
+            os.Exit(1)
+    }
+}
+
+// serve runs the node, and its JSON-RPC server and explorer if configured, until the listener is closed.
+// It fails if the blockchain can't be opened or an address listened on.
+func serve(nodeID string, config p2p.Config) error {
+    bc, err := chain.NewBlockchain(nodeID)
+    if err != nil {
+            return err
+    }
+    defer bc.Close()
+
+    n := p2p.NewNode(config, bc)
+    err = n.Listen()
+    if err != nil {
+            return err
+    }
+    defer n.Close()
+
+    if config.RPC != "" {
+            s := rpc.NewServer(bc, n, fmt.Sprintf(wallet.WalletFile, nodeID))
+            err = s.Listen(config.RPC)
+            if err != nil {
+                    return err
+            }
+            defer s.Close()
+            go s.Serve()
+            fmt.Printf("JSON-RPC server listening on %s\n", s.Addr())
+    }
+    if config.Explorer != "" {
+            e := explorer.NewServer(bc, n.Mempool())
+            err = e.Listen(config.Explorer)
+            if err != nil {
+                    return err
+            }
+            defer e.Close()
+            go e.Serve()
+            fmt.Printf("Explorer API listening on %s\n", e.Addr())
+    }
+
+    n.Serve()
+    return nil
+}
+
+// nodeConfig reads the config file, if one is given, and applies the command line flags on top of it.
+// Without a listening address the node keeps listening on localhost:NODE_ID.
+func nodeConfig(path, listen, advertise, peers, miner, rpcAddr, explorerAddr, nodeID string) p2p.Config {
+    config := &p2p.Config{}
+    if path != "" {
+            var err error
+            config, err = p2p.LoadConfig(path)
+            if err != nil {
+                    log.Panic(err)
+            }
+    }
+    if listen != "" {
+            config.Listen = listen
+    }
+    if advertise != "" {
+            config.Advertise = advertise
+    }
+    if peers != "" {
+            config.Peers = p2p.ParsePeers(peers)
+    }
+    if miner != "" {
+            config.Miner = miner
+    }
+    if rpcAddr != "" {
+            config.RPC = rpcAddr
+    }
+    if explorerAddr != "" {
+            config.Explorer = explorerAddr
+    }
+    if config.Listen == "" {
+            config.Listen = fmt.Sprintf("localhost:%s", nodeID)
+    }
+
+    return *config
+}
@@ -0,0 +1,171 @@
+package explorer
+
+import (
+    "encoding/json"
+    "log"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/gorilla/websocket"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/rpc"
+    "blockchain_go/transaction"
+)
+
+const feedQueueLength = 64         // events queued for a subscriber before it's dropped as too slow
+const feedPingInterval = 30 * time.Second
+const feedWriteTimeout = 10 * time.Second
+
+// Event is a message of the feed: the main chain got a new tip, or the mempool took in a transaction
+type Event struct {
+    Type  string                 `json:"type"` // "tip" or "tx"
+    Block *BlockSummary          `json:"block,omitempty"`
+    Tx    *rpc.TransactionResult `json:"tx,omitempty"`
+}
+
+// Feed pushes the events of a node to the WebSocket connections subscribed to it.
+// Subscribers only listen, what they send is ignored. One that doesn't keep up is disconnected
+// rather than holding up the node, it can reconnect and catch up through the REST API.
+type Feed struct {
+    upgrader websocket.Upgrader
+
+    mu          sync.Mutex // guards the fields below
+    subscribers map[*subscriber]bool
+    closed      bool
+}
+
+type subscriber struct {
+    conn *websocket.Conn
+    send chan []byte
+    done chan struct{} // closed once the subscriber is dropped
+}
+
+// NewFeed creates a feed following the chain and the mempool
+func NewFeed(bc *chain.Blockchain, mempool *chain.Mempool) *Feed {
+    f := &Feed{
+        upgrader:    websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}, // the feed is read-only
+        subscribers: make(map[*subscriber]bool),
+    }
+    bc.OnNewTip(func(b *block.Block) {
+        summary := newBlockSummary(b)
+        err := f.publish(Event{Type: "tip", Block: &summary})
+        if err != nil {
+            log.Printf("Feed: can't publish block %x: %s", b.Hash, err)
+        }
+    })
+    mempool.OnAdd(func(tx *transaction.Transaction) {
+        result := rpc.NewTransactionResult(tx)
+        err := f.publish(Event{Type: "tx", Tx: &result})
+        if err != nil {
+            log.Printf("Feed: can't publish transaction %x: %s", tx.ID, err)
+        }
+    })
+
+    return f
+}
+
+// ServeHTTP subscribes a WebSocket connection to the feed
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    conn, err := f.upgrader.Upgrade(w, r, nil)
+    if err != nil {
+        return // the upgrader already answered
+    }
+    s := &subscriber{conn: conn, send: make(chan []byte, feedQueueLength), done: make(chan struct{})}
+
+    f.mu.Lock()
+    if f.closed {
+        f.mu.Unlock()
+        conn.Close()
+        return
+    }
+    f.subscribers[s] = true
+    f.mu.Unlock()
+
+    go f.write(s)
+    for { // reading is what notices the connection went away, and answers pings
+        _, _, err := conn.ReadMessage()
+        if err != nil {
+            f.drop(s)
+            return
+        }
+    }
+}
+
+// Close disconnects every subscriber, later ones are turned away
+func (f *Feed) Close() {
+    f.mu.Lock()
+    f.closed = true
+    var subscribers []*subscriber
+    for s := range f.subscribers {
+        subscribers = append(subscribers, s)
+    }
+    f.mu.Unlock()
+
+    for _, s := range subscribers {
+        f.drop(s)
+    }
+}
+
+// publish queues an event for every subscriber, dropping those whose queue is full
+func (f *Feed) publish(event Event) error {
+    data, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+
+    var slow []*subscriber
+    f.mu.Lock()
+    for s := range f.subscribers {
+        select {
+        case s.send <- data:
+        default:
+            slow = append(slow, s)
+        }
+    }
+    f.mu.Unlock()
+
+    for _, s := range slow {
+        f.drop(s)
+    }
+    return nil
+}
+
+// write sends the queued events to a subscriber and pings it while there are none
+func (f *Feed) write(s *subscriber) {
+    ticker := time.NewTicker(feedPingInterval)
+    defer ticker.Stop()
+
+    for {
+        var err error
+        select {
+        case data := <-s.send:
+            s.conn.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+            err = s.conn.WriteMessage(websocket.TextMessage, data)
+        case <-ticker.C:
+            err = s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteTimeout))
+        case <-s.done:
+            return
+        }
+        if err != nil {
+            f.drop(s)
+            return
+        }
+    }
+}
+
+// drop unsubscribes a subscriber and closes its connection, once however often it's called
+func (f *Feed) drop(s *subscriber) {
+    f.mu.Lock()
+    if !f.subscribers[s] {
+        f.mu.Unlock()
+        return
+    }
+    delete(f.subscribers, s)
+    f.mu.Unlock()
+
+    close(s.done)
+    s.conn.Close()
+}
@@ -0,0 +1,313 @@
+package explorer
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/rpc"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+const defaultBlocksLimit = 10
+const maxBlocksLimit = 100
+
+// Server is a read-only HTTP API over the chain and the mempool of a node, answering GET requests with JSON:
+//
+//    /api/status                    height, best block hash and mempool size
+//    /api/blocks?start=H&limit=N    summaries of the main chain blocks from height H down, the tip by default
+//    /api/blocks/HASH or HEIGHT     a block with its transactions
+//    /api/tx/TXID                   a main chain or mempool transaction
+//    /api/address/ADDRESS           balance and number of unspent outputs
+//    /api/address/ADDRESS/utxos     unspent outputs
+//    /api/address/ADDRESS/history   transactions paying to or spending from the address, needs the address index
+//    /api/mempool                   transactions waiting to be mined, best fee rates first
+//    /api/ws                        WebSocket feed of new tips and mempool transactions, see Feed
+//
+// Blocks and transactions have the same shape as in the JSON-RPC API.
+type Server struct {
+    bc      *chain.Blockchain
+    mempool *chain.Mempool
+    feed    *Feed
+    mux     *http.ServeMux
+
+    ln   net.Listener
+    http *http.Server
+}
+
+// BlockSummary is a block as /api/blocks lists it
+type BlockSummary struct {
+    Hash    string `json:"hash"`
+    Height  int    `json:"height"`
+    Time    int64  `json:"time"`
+    TxCount int    `json:"txcount"`
+    Size    int    `json:"size"`
+}
+
+// Status is the answer to /api/status
+type Status struct {
+    Height   int             `json:"height"`
+    BestHash string          `json:"besthash"`
+    Mempool  rpc.MempoolInfo `json:"mempool"`
+}
+
+// AddressInfo is the answer to /api/address/ADDRESS
+type AddressInfo struct {
+    Address string `json:"address"`
+    Balance int    `json:"balance"`
+    Unspent int    `json:"unspent"` // number of unspent outputs
+}
+
+// HistoryEntry is a transaction in the answer to /api/address/ADDRESS/history
+type HistoryEntry struct {
+    Txid   string `json:"txid"`
+    Height int    `json:"height"`
+}
+
+// MempoolResult is the answer to /api/mempool
+type MempoolResult struct {
+    rpc.MempoolInfo
+    Transactions []rpc.TransactionResult `json:"tx"`
+}
+
+// errorResult is the body of the answers to failed requests
+type errorResult struct {
+    Error string `json:"error"`
+}
+
+// NewServer creates an explorer for the chain and mempool of a node. The feed starts
+// following them right away.
+func NewServer(bc *chain.Blockchain, mempool *chain.Mempool) *Server {
+    s := &Server{bc: bc, mempool: mempool, feed: NewFeed(bc, mempool), mux: http.NewServeMux()}
+    s.mux.HandleFunc("/api/status", s.handleStatus)
+    s.mux.HandleFunc("/api/blocks", s.handleBlocks)
+    s.mux.HandleFunc("/api/blocks/", s.handleBlock)
+    s.mux.HandleFunc("/api/tx/", s.handleTx)
+    s.mux.HandleFunc("/api/address/", s.handleAddress)
+    s.mux.HandleFunc("/api/mempool", s.handleMempool)
+    s.mux.Handle("/api/ws", s.feed)
+    s.http = &http.Server{Handler: s, ReadHeaderTimeout: 30 * time.Second}
+
+    return s
+}
+
+// Listen opens the server's listening socket on host:port
+func (s *Server) Listen(addr string) error {
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+        return err
+    }
+    s.ln = ln
+
+    return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+    return s.ln.Addr().String()
+}
+
+// Serve answers requests until the server is closed
+func (s *Server) Serve() {
+    s.http.Serve(s.ln)
+}
+
+// Close stops the server and disconnects the feed's subscribers
+func (s *Server) Close() error {
+    s.feed.Close()
+    return s.http.Close()
+}
+
+// ServeHTTP only lets reads through, the dashboard may be on another origin
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        w.Header().Set("Allow", http.MethodGet)
+        writeError(w, http.StatusMethodNotAllowed, "the explorer is read-only")
+        return
+    }
+    w.Header().Set("Access-Control-Allow-Origin", "*")
+    s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+    writeJSON(w, Status{s.bc.GetBestHeight(), hex.EncodeToString(s.bc.Tip()), rpc.MempoolInfo{Size: s.mempool.Count(), Bytes: s.mempool.Size()}})
+}
+
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+    start := s.bc.GetBestHeight()
+    limit := defaultBlocksLimit
+    var err error
+    if v := r.URL.Query().Get("start"); v != "" {
+        start, err = strconv.Atoi(v)
+        if err != nil || start < 0 {
+            writeError(w, http.StatusBadRequest, "start must be a height")
+            return
+        }
+    }
+    if v := r.URL.Query().Get("limit"); v != "" {
+        limit, err = strconv.Atoi(v)
+        if err != nil || limit < 1 || limit > maxBlocksLimit {
+            writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+            return
+        }
+    }
+
+    from := start - limit + 1
+    if from < 0 {
+        from = 0
+    }
+    summaries := []BlockSummary{}
+    err = s.bc.RangeBlocks(from, start, func(b *block.Block) bool {
+        summaries = append(summaries, newBlockSummary(b))
+        return true
+    })
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    for i, j := 0, len(summaries)-1; i < j; i, j = i+1, j-1 { // newest first
+        summaries[i], summaries[j] = summaries[j], summaries[i]
+    }
+    writeJSON(w, summaries)
+}
+
+// handleBlock looks blocks up by hash, or by height on the main chain
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+    id := strings.TrimPrefix(r.URL.Path, "/api/blocks/")
+
+    var b block.Block
+    var err error
+    if height, convErr := strconv.Atoi(id); convErr == nil && len(id) < 64 {
+        b, err = s.bc.GetBlockByHeight(height)
+    } else {
+        hash, decodeErr := hex.DecodeString(id)
+        if decodeErr != nil {
+            writeError(w, http.StatusBadRequest, "a block is asked for by hash or height")
+            return
+        }
+        b, err = s.bc.GetBlock(hash)
+    }
+    if errors.Is(err, chain.ErrBlockNotFound) {
+        writeError(w, http.StatusNotFound, "block is not found")
+        return
+    }
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+
+    writeJSON(w, rpc.NewBlockResult(&b, s.bc.Confirmations(&b)))
+}
+
+func (s *Server) handleTx(w http.ResponseWriter, r *http.Request) {
+    id, err := hex.DecodeString(strings.TrimPrefix(r.URL.Path, "/api/tx/"))
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "transaction ID is not hex")
+        return
+    }
+
+    tx, b, err := s.bc.GetTransaction(id)
+    if err == nil {
+        writeJSON(w, rpc.NewConfirmedTransactionResult(tx, b, s.bc.GetBestHeight()))
+        return
+    }
+    if !errors.Is(err, chain.ErrTransactionNotFound) {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    if tx, ok := s.mempool.Get(id); ok {
+        writeJSON(w, rpc.NewTransactionResult(tx))
+        return
+    }
+
+    writeError(w, http.StatusNotFound, "transaction is not found")
+}
+
+// handleAddress answers /api/address/ADDRESS and the utxos and history below it
+func (s *Server) handleAddress(w http.ResponseWriter, r *http.Request) {
+    parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/address/"), "/")
+    address := parts[0]
+    if !wallet.ValidateAddress(address) {
+        writeError(w, http.StatusBadRequest, "address is not valid")
+        return
+    }
+    pubKeyHash := wallet.Base58Decode([]byte(address))
+    pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
+    UTXOSet := utxo.UTXOSet{Blockchain: s.bc}
+
+    switch {
+    case len(parts) == 1:
+        unspent := UTXOSet.ListUnspent(pubKeyHash)
+        info := AddressInfo{Address: address, Unspent: len(unspent)}
+        for _, u := range unspent {
+            info.Balance += u.Value
+        }
+        writeJSON(w, info)
+    case len(parts) == 2 && parts[1] == "utxos":
+        result := []rpc.UnspentResult{}
+        bestHeight := s.bc.GetBestHeight()
+        for _, u := range UTXOSet.ListUnspent(pubKeyHash) {
+            result = append(result, rpc.UnspentResult{
+                Txid:          hex.EncodeToString(u.Txid),
+                Vout:          u.Vout,
+                Value:         u.Value,
+                Height:        u.Height,
+                Confirmations: bestHeight - u.Height + 1,
+                Coinbase:      u.Coinbase,
+            })
+        }
+        writeJSON(w, result)
+    case len(parts) == 2 && parts[1] == "history":
+        history, enabled := UTXOSet.AddressHistory(pubKeyHash)
+        if !enabled {
+            writeError(w, http.StatusNotImplemented, "the address index is off, run reindexutxo -addrindex")
+            return
+        }
+        result := []HistoryEntry{}
+        for _, entry := range history {
+            result = append(result, HistoryEntry{hex.EncodeToString(entry.Txid), entry.Height})
+        }
+        writeJSON(w, result)
+    default:
+        writeError(w, http.StatusNotFound, "not found")
+    }
+}
+
+func (s *Server) handleMempool(w http.ResponseWriter, r *http.Request) {
+    result := MempoolResult{Transactions: []rpc.TransactionResult{}}
+    for _, tx := range s.mempool.Transactions() {
+        result.Transactions = append(result.Transactions, rpc.NewTransactionResult(tx))
+        result.Size++
+        result.Bytes += tx.Size()
+    }
+    writeJSON(w, result)
+}
+
+func newBlockSummary(b *block.Block) BlockSummary {
+    return BlockSummary{hex.EncodeToString(b.Hash), b.Height, b.Timestamp, len(b.Transactions), len(b.Serialize())}
+}
+
+// writeJSON answers with v, or with a 500 if it can't be encoded
+func writeJSON(w http.ResponseWriter, v interface{}) {
+    data, err := json.Marshal(v)
+    if err != nil {
+        writeError(w, http.StatusInternalServerError, err.Error())
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(errorResult{message})
+}
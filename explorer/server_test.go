@@ -0,0 +1,136 @@
+package explorer
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gorilla/websocket"
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/chain"
+    "blockchain_go/rpc"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// get fetches path from the server and decodes the JSON answer into v, returning the status
+func get(t *testing.T, ts *httptest.Server, path string, v interface{}) int {
+    resp, err := http.Get(ts.URL + path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer resp.Body.Close()
+    if v != nil {
+        assert.Nil(t, json.NewDecoder(resp.Body).Decode(v), path)
+    }
+    return resp.StatusCode
+}
+
+func TestExplorer(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    w := wallet.NewWallet()
+    address := string(w.GetAddress())
+    bc, err := chain.CreateBlockchainFile(dbFile, address)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer bc.Close()
+    mempool := chain.NewMempool(chain.DefaultMempoolSize, chain.DefaultMempoolExpiry)
+    s := NewServer(bc, mempool)
+    ts := httptest.NewServer(s)
+    defer ts.Close()
+    defer s.Close()
+
+    feed, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/ws", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer feed.Close()
+    next := func() Event {
+        var event Event
+        feed.SetReadDeadline(time.Now().Add(5 * time.Second))
+        assert.Nil(t, feed.ReadJSON(&event))
+        return event
+    }
+    time.Sleep(50 * time.Millisecond) // the subscription is registered once the upgrade is handled
+
+    var blocks []string
+    for i := 1; i <= 3; i++ {
+        b, _, _, err := bc.MineBlock(nil, func(height int) *transaction.Transaction {
+            return transaction.NewCoinbaseTX(address, "", height, 0)
+        })
+        assert.Nil(t, err)
+        blocks = append(blocks, hex.EncodeToString(b.Hash))
+        event := next()
+        if assert.Equal(t, "tip", event.Type) {
+            assert.Equal(t, blocks[i-1], event.Block.Hash)
+        }
+    }
+    tx, err := utxo.NewUTXOTransaction(w, string(wallet.NewWallet().GetAddress()), 3, 1, &utxo.UTXOSet{Blockchain: bc})
+    assert.Nil(t, err)
+    assert.Nil(t, mempool.Add(tx, bc))
+    event := next()
+    if assert.Equal(t, "tx", event.Type) {
+        assert.Equal(t, hex.EncodeToString(tx.ID), event.Tx.ID)
+    }
+
+    var status Status
+    assert.Equal(t, http.StatusOK, get(t, ts, "/api/status", &status))
+    assert.Equal(t, Status{3, blocks[2], rpc.MempoolInfo{Size: 1, Bytes: tx.Size()}}, status)
+
+    var summaries []BlockSummary
+    get(t, ts, "/api/blocks?limit=2", &summaries)
+    if assert.Len(t, summaries, 2) {
+        assert.Equal(t, blocks[2], summaries[0].Hash, "Newest first")
+        assert.Equal(t, blocks[1], summaries[1].Hash)
+    }
+    get(t, ts, "/api/blocks?start=1&limit=5", &summaries)
+    assert.Len(t, summaries, 2, "The range stops at the genesis block")
+
+    var blk rpc.BlockResult
+    assert.Equal(t, http.StatusOK, get(t, ts, "/api/blocks/2", &blk))
+    assert.Equal(t, blocks[1], blk.Hash)
+    assert.Equal(t, 2, blk.Confirmations)
+    assert.Equal(t, http.StatusOK, get(t, ts, "/api/blocks/"+blocks[0], &blk))
+    assert.Equal(t, 1, blk.Height)
+    assert.Equal(t, http.StatusNotFound, get(t, ts, "/api/blocks/9", nil))
+    assert.Equal(t, http.StatusBadRequest, get(t, ts, "/api/blocks/xyz", nil))
+
+    var result rpc.TransactionResult
+    assert.Equal(t, http.StatusOK, get(t, ts, "/api/tx/"+hex.EncodeToString(tx.ID), &result))
+    assert.Nil(t, result.BlockHeight, "Mempool transactions aren't in a block yet")
+    get(t, ts, "/api/tx/"+blk.Transactions[0].ID, &result)
+    assert.Equal(t, 1, *result.BlockHeight)
+    assert.Equal(t, http.StatusNotFound, get(t, ts, "/api/tx/"+strings.Repeat("00", 32), nil))
+
+    var info AddressInfo
+    get(t, ts, "/api/address/"+address, &info)
+    assert.Equal(t, AddressInfo{address, 4 * transaction.GetBlockSubsidy(0), 4}, info, "Mempool transactions don't count yet")
+    var unspent []rpc.UnspentResult
+    get(t, ts, "/api/address/"+address+"/utxos", &unspent)
+    assert.Len(t, unspent, 4)
+    assert.Equal(t, http.StatusNotImplemented, get(t, ts, "/api/address/"+address+"/history", nil))
+    assert.Nil(t, (utxo.UTXOSet{Blockchain: bc}).ReindexAddresses())
+    var history []HistoryEntry
+    get(t, ts, "/api/address/"+address+"/history", &history)
+    assert.Len(t, history, 4, "The genesis and the three mined coinbases")
+    assert.Equal(t, http.StatusBadRequest, get(t, ts, "/api/address/nope", nil))
+
+    var pool MempoolResult
+    get(t, ts, "/api/mempool", &pool)
+    assert.Equal(t, 1, pool.Size)
+    assert.Equal(t, hex.EncodeToString(tx.ID), pool.Transactions[0].ID)
+
+    resp, err := http.Post(ts.URL+"/api/status", "application/json", nil)
+    assert.Nil(t, err)
+    resp.Body.Close()
+    assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, "The explorer is read-only")
+}
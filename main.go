@@ -1,7 +1,9 @@
 package main
 
+import "blockchain_go/cli"
+
 // ./blockchain-in-go createblockchain -address Ivan
 func main() {
-    cli := CLI{}
-    cli.Run()
+    c := cli.CLI{}
+    c.Run()
 }
@@ -0,0 +1,52 @@
+package p2p
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "strings"
+)
+
+// Config tells a node where to listen and which peers to bootstrap from.
+// It can be read from a JSON file such as
+//
+//    {"listen": "0.0.0.0:3000", "advertise": "10.0.0.5:3000", "peers": ["10.0.0.6:3000"],
+//     "rpc": "localhost:4000", "explorer": "0.0.0.0:4001"}
+//
+// and command line flags override what the file says.
+type Config struct {
+    Listen    string   `json:"listen"`    // host:port the node accepts peers on
+    Advertise string   `json:"advertise"` // host:port other nodes reach us at, the listening address if empty
+    Peers     []string `json:"peers"`     // bootstrap peers dialed at startup; more are learned from them
+    Miner     string   `json:"miner"`     // address receiving the rewards, mining is off if empty
+    RPC       string   `json:"rpc"`       // host:port of the JSON-RPC server, off if empty
+    Explorer  string   `json:"explorer"`  // host:port of the read-only explorer API, off if empty
+}
+
+// LoadConfig reads a config file
+func LoadConfig(path string) (*Config, error) {
+    data, err := ioutil.ReadFile(path)
+    if err != nil {
+            return nil, err
+    }
+
+    config := &Config{}
+    err = json.Unmarshal(data, config)
+    if err != nil {
+            return nil, err
+    }
+
+    return config, nil
+}
+
+// ParsePeers splits a comma separated list of host:port addresses
+func ParsePeers(list string) []string {
+    var peers []string
+    for _, peer := range strings.Split(list, ",") {
+            peer = strings.TrimSpace(peer)
+            if peer != "" {
+                    peers = append(peers, peer)
+            }
+    }
+
+    return peers
+}
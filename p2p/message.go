@@ -0,0 +1,84 @@
+package p2p
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/binary"
+    "fmt"
+    "io"
+)
+
+// Every message on the wire starts with a header, like Bitcoin's:
+//
+//    magic (4) | command (12) | payload length (4) | checksum (4)
+//
+// followed by the payload. The length lets a connection carry any number of
+// messages, the checksum (computed like the one of addresses)
+// catches corrupt ones, and the magic tells our network from anything else.
+const messageHeaderLength = 4 + commandLength + 4 + checksumLength
+const checksumLength = 4
+const maxPayloadSize = 32 << 20 // the largest message a node will read
+
+var networkMagic = []byte{0xfa, 0xce, 0xb0, 0x0c}
+
+// writeMessage frames payload and writes it to w in one go
+func writeMessage(w io.Writer, command string, payload []byte) error {
+    if len(command) > commandLength {
+            return fmt.Errorf("command %q is longer than %d bytes", command, commandLength)
+    }
+    if len(payload) > maxPayloadSize {
+            return fmt.Errorf("%s payload of %d bytes is over the limit", command, len(payload))
+    }
+
+    var buff bytes.Buffer
+    buff.Write(networkMagic)
+    buff.Write(commandToBytes(command))
+    binary.Write(&buff, binary.BigEndian, uint32(len(payload)))
+    buff.Write(checksum(payload))
+    buff.Write(payload)
+
+    _, err := w.Write(buff.Bytes())
+    return err
+}
+
+// readMessage reads the next framed message from r.
+// It returns io.EOF when r is closed between messages; oversized or corrupt
+// frames are errors, after which the rest of the stream can't be trusted.
+func readMessage(r io.Reader) (string, []byte, error) {
+    header := make([]byte, messageHeaderLength)
+    _, err := io.ReadFull(r, header)
+    if err != nil {
+            return "", nil, err
+    }
+    if !bytes.Equal(header[:4], networkMagic) {
+            return "", nil, fmt.Errorf("bad magic %x", header[:4])
+    }
+
+    command := bytesToCommand(header[4 : 4+commandLength])
+    length := binary.BigEndian.Uint32(header[4+commandLength:])
+    if length > maxPayloadSize {
+            return "", nil, fmt.Errorf("%s payload of %d bytes is over the limit", command, length)
+    }
+
+    payload := make([]byte, length)
+    _, err = io.ReadFull(r, payload)
+    if err == io.EOF {
+            err = io.ErrUnexpectedEOF
+    }
+    if err != nil {
+            return "", nil, err
+    }
+    if !bytes.Equal(header[4+commandLength+4:], checksum(payload)) {
+            return "", nil, fmt.Errorf("bad checksum for %s", command)
+    }
+
+    return command, payload, nil
+}
+
+// checksum is the first checksumLength bytes of the double SHA-256 of payload
+func checksum(payload []byte) []byte {
+    firstSHA := sha256.Sum256(payload)
+    secondSHA := sha256.Sum256(firstSHA[:])
+
+    return secondSHA[:checksumLength]
+}
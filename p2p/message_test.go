@@ -0,0 +1,58 @@
+package p2p
+
+import (
+    "bytes"
+    "io"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMessageFraming(t *testing.T) {
+    var stream bytes.Buffer
+    assert.Nil(t, writeMessage(&stream, "version", []byte("first")))
+    assert.Nil(t, writeMessage(&stream, "getblocks", []byte{}))
+    assert.Equal(t, 2*messageHeaderLength+5, stream.Len())
+
+    command, payload, err := readMessage(&stream)
+    assert.Nil(t, err)
+    assert.Equal(t, "version", command)
+    assert.Equal(t, []byte("first"), payload)
+
+    command, payload, err = readMessage(&stream)
+    assert.Nil(t, err)
+    assert.Equal(t, "getblocks", command, "A connection carries many messages")
+    assert.Empty(t, payload)
+
+    _, _, err = readMessage(&stream)
+    assert.Equal(t, io.EOF, err)
+}
+
+func TestMessageFramingRejects(t *testing.T) {
+    frame := func() []byte {
+            var buff bytes.Buffer
+            writeMessage(&buff, "tx", []byte("payload"))
+            return buff.Bytes()
+    }
+
+    data := frame()
+    data[0] ^= 0xff
+    _, _, err := readMessage(bytes.NewReader(data))
+    assert.NotNil(t, err, "Wrong magic is rejected")
+
+    data = frame()
+    data[len(data)-1] ^= 0xff
+    _, _, err = readMessage(bytes.NewReader(data))
+    assert.NotNil(t, err, "Corrupt payload is rejected")
+
+    data = frame()
+    copy(data[4+commandLength:], []byte{0xff, 0xff, 0xff, 0xff})
+    _, _, err = readMessage(bytes.NewReader(data))
+    assert.NotNil(t, err, "Oversized payload is rejected")
+
+    data = frame()
+    _, _, err = readMessage(bytes.NewReader(data[:len(data)-1]))
+    assert.Equal(t, io.ErrUnexpectedEOF, err, "Truncated frame is rejected")
+
+    assert.NotNil(t, writeMessage(&bytes.Buffer{}, "averylongcommand", nil))
+}
@@ -0,0 +1,519 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/gob"
+    "fmt"
+    "io"
+    "math/rand"
+    "net"
+    "sync"
+    "time"
+)
+
+const maxOutboundPeers = 8
+const maxInboundPeers = 117
+const sendQueueLength = 1024       // messages waiting for a peer before it is dropped as too slow
+const dialTimeout = 5 * time.Second
+const nodeNetwork uint64 = 1       // service bit: the node stores and serves full blocks
+
+// vars rather than consts so tests can speed them up
+var pingInterval = 30 * time.Second
+var peerTimeout = 90 * time.Second // a peer silent for this long is considered dead
+var maintainInterval = time.Second
+var minDialBackoff = time.Second
+var maxDialBackoff = 10 * time.Minute
+
+type message struct {
+    command string
+    payload []byte
+}
+
+type ping struct {
+    Nonce uint64
+}
+
+type pong struct {
+    Nonce uint64
+}
+
+// Peer is a long-lived connection to another node, dialed by us (outbound) or by it (inbound).
+// Messages to a peer are queued and written by their own goroutine, so a slow
+// peer doesn't hold up the node.
+type Peer struct {
+    conn    net.Conn
+    inbound bool
+    send    chan message
+
+    mu         sync.Mutex // guards the fields below
+    addr       string     // address we dialed, or the one an inbound peer connects from
+    listenAddr string     // address an inbound peer says it listens on, only used to not dial it again
+    version    int        // negotiated protocol version, 0 until the peer's version arrived
+    services   uint64
+    bestHeight int
+    handshaked bool       // the peer acknowledged our version
+    lastSeen   time.Time
+    lastPing   time.Time
+    pingNonce  uint64
+    closed     bool
+}
+
+// dialState remembers failed dials so a node that's down is retried less and less often
+type dialState struct {
+    failures int
+    next     time.Time
+}
+
+func newPeer(conn net.Conn, inbound bool) *Peer {
+    now := time.Now()
+    return &Peer{
+            conn:     conn,
+            inbound:  inbound,
+            send:     make(chan message, sendQueueLength),
+            lastSeen: now,
+            lastPing: now,
+    }
+}
+
+// Addr returns the address of the peer: the one we dialed, or the one an inbound peer connects from
+func (p *Peer) Addr() string {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    return p.addr
+}
+
+// BestHeight returns the height of the best block the peer is known to have
+func (p *Peer) BestHeight() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    return p.bestHeight
+}
+
+// Inbound tells whether the peer dialed us
+func (p *Peer) Inbound() bool {
+    return p.inbound
+}
+
+// RemoteAddr returns the address the connection to the peer comes from
+func (p *Peer) RemoteAddr() string {
+    return p.conn.RemoteAddr().String()
+}
+
+// host returns the IP the peer connects from. Misbehaviour is scored by it rather than
+// by the address a peer announces, which it could set to get another node banned or to dodge a ban.
+// Nodes sharing a host are banned together.
+func (p *Peer) host() string {
+    return hostOf(p.RemoteAddr())
+}
+
+func hostOf(addr string) string {
+    host, _, err := net.SplitHostPort(addr)
+    if err != nil {
+            return addr
+    }
+    return host
+}
+
+// Version returns the protocol version negotiated with the peer
+func (p *Peer) Version() int {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    return p.version
+}
+
+func (p *Peer) isClosed() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    return p.closed
+}
+
+func (p *Peer) isHandshaked() bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    return p.handshaked
+}
+
+// queue hands a message to the peer's writer. A peer whose queue is full is disconnected.
+func (p *Peer) queue(command string, payload []byte) bool {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if p.closed {
+            return false
+    }
+    select {
+    case p.send <- message{command, payload}:
+            return true
+    default:
+            fmt.Printf("Send queue of %s is full, disconnecting\n", p.conn.RemoteAddr())
+            p.closed = true
+            close(p.send)
+            return false
+    }
+}
+
+// disconnect stops the peer; messages already queued are still written before the connection is closed
+func (p *Peer) disconnect() {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+
+    if !p.closed {
+            p.closed = true
+            close(p.send)
+    }
+}
+
+// writePeer writes the queued messages until the peer is disconnected, then closes the connection
+func (n *Node) writePeer(p *Peer) {
+    defer n.wg.Done()
+    defer p.conn.Close()
+
+    for msg := range p.send {
+            p.conn.SetWriteDeadline(time.Now().Add(peerTimeout))
+            err := writeMessage(p.conn, msg.command, msg.payload)
+            if err != nil {
+                    fmt.Printf("Sending to %s failed: %s\n", p.conn.RemoteAddr(), err)
+                    n.dropPeer(p)
+                    return
+            }
+    }
+}
+
+// readPeer handles the messages of a peer in order until the connection breaks.
+// The first message must be a version; a malformed frame or a payload that can't
+// be decoded ends the connection, as nothing after it can be trusted.
+func (n *Node) readPeer(p *Peer) {
+    defer n.wg.Done()
+    defer n.dropPeer(p)
+
+    for {
+            command, request, err := readMessage(p.conn) //When a node receives a command, it reads the frame header to extract command name and processes command body with correct handler
+            if err != nil {
+                    if err != io.EOF {
+                            fmt.Printf("Dropping connection with %s: %s\n", p.conn.RemoteAddr(), err)
+                    }
+                    return
+            }
+            fmt.Printf("Received %s command\n", command)
+
+            p.mu.Lock()
+            p.lastSeen = time.Now()
+            versionKnown := p.version != 0
+            p.mu.Unlock()
+            if !versionKnown && command != "version" {
+                    fmt.Printf("%s sent %s before its version\n", p.conn.RemoteAddr(), command)
+                    return
+            }
+
+            err = n.handleMessage(p, command, request)
+            if err != nil {
+                    fmt.Printf("Dropping connection with %s, bad %s message: %s\n", p.conn.RemoteAddr(), command, err)
+                    return
+            }
+    }
+}
+
+// connect dials addr and starts the handshake by sending our version.
+// It returns nil if the node can't be reached, or shouldn't be dialed right now.
+func (n *Node) connect(addr string) *Peer {
+    n.mu.Lock()
+    dial := n.dials[addr]
+    if n.closing || n.banScores[hostOf(addr)] >= banThreshold || n.countPeers(false) >= maxOutboundPeers ||
+            (dial != nil && time.Now().Before(dial.next)) {
+            n.mu.Unlock()
+            return nil
+    }
+    n.mu.Unlock()
+
+    conn, err := net.DialTimeout(protocol, addr, dialTimeout)
+    if err != nil {
+            fmt.Printf("%s is not available\n", addr)
+            n.dialFailed(addr)
+            return nil
+    }
+
+    n.mu.Lock()
+    delete(n.dials, addr)
+    n.mu.Unlock()
+
+    p := newPeer(conn, false)
+    p.addr = addr
+    if !n.addPeer(p) {
+            conn.Close()
+            return nil
+    }
+    p.queue("version", n.versionPayload())
+
+    return p
+}
+
+// dialFailed backs off from addr: the wait before the next attempt doubles with every failure
+func (n *Node) dialFailed(addr string) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    dial := n.dials[addr]
+    if dial == nil {
+            dial = &dialState{}
+            n.dials[addr] = dial
+    }
+    backoff := maxDialBackoff
+    if dial.failures < 16 && minDialBackoff<<uint(dial.failures) < maxDialBackoff {
+            backoff = minDialBackoff << uint(dial.failures)
+    }
+    dial.failures++
+    dial.next = time.Now().Add(backoff)
+}
+
+// accept takes an inbound connection, unless the node already has as many as it allows
+func (n *Node) accept(conn net.Conn) {
+    n.mu.Lock()
+    full := n.countPeers(true) >= maxInboundPeers
+    n.mu.Unlock()
+    if full {
+            fmt.Printf("Too many inbound peers, refusing %s\n", conn.RemoteAddr())
+            conn.Close()
+            return
+    }
+
+    p := newPeer(conn, true)
+    p.addr = conn.RemoteAddr().String()
+    if !n.addPeer(p) {
+            conn.Close()
+    }
+}
+
+// addPeer registers the peer and starts its reader and writer
+func (n *Node) addPeer(p *Peer) bool {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    if n.closing {
+            return false
+    }
+    if n.banScores[p.host()] >= banThreshold {
+            fmt.Printf("%s is banned\n", p.conn.RemoteAddr())
+            return false
+    }
+    n.peers[p] = true
+    n.wg.Add(2)
+    go n.writePeer(p)
+    go n.readPeer(p)
+
+    return true
+}
+
+// dropPeer disconnects the peer and forgets the connection; the node itself stays known and may be dialed again
+func (n *Node) dropPeer(p *Peer) {
+    n.mu.Lock()
+    delete(n.peers, p)
+    n.mu.Unlock()
+
+    p.disconnect()
+}
+
+// countPeers returns the number of inbound or outbound peers; n.mu must be held
+func (n *Node) countPeers(inbound bool) int {
+    count := 0
+    for p := range n.peers {
+            if p.inbound == inbound {
+                    count++
+            }
+    }
+
+    return count
+}
+
+// peerByAddr returns the peer we dialed at addr or that connects from it, if there is one
+func (n *Node) peerByAddr(addr string) *Peer {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    for p := range n.peers {
+            if p.Addr() == addr {
+                    return p
+            }
+    }
+
+    return nil
+}
+
+// connectedTo tells whether we're connected to the node listening on addr, either way.
+// An inbound peer is taken at its word here, the worst it can do is keep us from dialing addr.
+func (n *Node) connectedTo(addr string) bool {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    for p := range n.peers {
+            p.mu.Lock()
+            found := p.addr == addr || p.listenAddr == addr
+            p.mu.Unlock()
+            if found {
+                    return true
+            }
+    }
+
+    return false
+}
+
+// Peers returns the peers that completed the handshake
+func (n *Node) Peers() []*Peer {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    var peers []*Peer
+    for p := range n.peers {
+            if p.isHandshaked() {
+                    peers = append(peers, p)
+            }
+    }
+
+    return peers
+}
+
+// maintainPeers pings the peers, drops those that stopped answering, dials
+// known nodes while there are free outbound slots and retries stalled downloads,
+// until the node is closed
+func (n *Node) maintainPeers() {
+    defer n.wg.Done()
+
+    ticker := time.NewTicker(maintainInterval)
+    defer ticker.Stop()
+
+    for {
+            n.mu.Lock()
+            var peers []*Peer
+            for p := range n.peers {
+                    peers = append(peers, p)
+            }
+            n.mu.Unlock()
+
+            for _, p := range peers {
+                    p.mu.Lock()
+                    silent := time.Since(p.lastSeen) > peerTimeout
+                    needsPing := p.handshaked && time.Since(p.lastPing) > pingInterval
+                    if needsPing {
+                            p.lastPing = time.Now()
+                            p.pingNonce = rand.Uint64()
+                    }
+                    nonce := p.pingNonce
+                    p.mu.Unlock()
+
+                    if silent {
+                            fmt.Printf("%s timed out\n", p.conn.RemoteAddr())
+                            n.dropPeer(p)
+                    } else if needsPing {
+                            p.queue("ping", gobEncode(ping{nonce}))
+                    }
+            }
+
+            for _, addr := range n.getKnownNodes() {
+                    if addr != n.address && !n.connectedTo(addr) {
+                            n.connect(addr)
+                    }
+            }
+            n.checkSync()
+
+            select {
+            case <-n.quit:
+                    return
+            case <-ticker.C:
+            }
+    }
+}
+
+func (n *Node) versionPayload() []byte {
+    return gobEncode(verzion{protocolVersion, nodeNetwork, n.bc.GetBestHeight(), n.address})
+}
+
+// handleVersion is the first half of the handshake. An inbound peer is answered
+// with our version, and either way the version is acknowledged with a verack.
+func (n *Node) handleVersion(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload verzion
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff) //decode the request and extract the payload
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+    if payload.Version < minProtocolVersion {
+        fmt.Printf("%s speaks protocol version %d, at least %d is needed\n", payload.AddrFrom, payload.Version, minProtocolVersion)
+        n.dropPeer(p)
+        return nil
+    }
+    p.mu.Lock()
+    if p.version != 0 { // the handshake happens once per connection
+        p.mu.Unlock()
+        return nil
+    }
+    // both sides speak the lower of the two versions
+    p.version = payload.Version
+    if p.version > protocolVersion {
+        p.version = protocolVersion
+    }
+    p.services = payload.Services
+    p.bestHeight = payload.BestHeight
+    if p.inbound {
+        p.listenAddr = payload.AddrFrom
+    }
+    p.mu.Unlock()
+
+    if p.inbound {
+        p.queue("version", n.versionPayload())
+    }
+    p.queue("verack", nil)
+    if payload.AddrFrom != "" {
+        n.addNode(payload.AddrFrom)
+    }
+    if p.inbound {
+        // tell the newcomer about the rest of the network
+        p.queue("addr", gobEncode(addr{n.getKnownNodes()}))
+    }
+
+    n.startSync(p) // every peer we talk to syncs headers first
+
+    return nil
+}
+
+// handleVerack completes the handshake
+func (n *Node) handleVerack(p *Peer) {
+    p.mu.Lock()
+    p.handshaked = true
+    p.mu.Unlock()
+}
+
+func (n *Node) handlePing(p *Peer, request []byte) error {
+    var payload ping
+
+    err := gob.NewDecoder(bytes.NewReader(request)).Decode(&payload)
+    if err != nil {
+        return err
+    }
+    p.queue("pong", gobEncode(pong{payload.Nonce}))
+
+    return nil
+}
+
+// handlePong checks the answer to our ping; readPeer already noted that the peer is alive
+func (n *Node) handlePong(p *Peer, request []byte) error {
+    var payload pong
+
+    err := gob.NewDecoder(bytes.NewReader(request)).Decode(&payload)
+    if err != nil {
+        return err
+    }
+    p.mu.Lock()
+    if payload.Nonce != p.pingNonce {
+            fmt.Printf("%s answered with an unexpected pong\n", p.conn.RemoteAddr())
+    }
+    p.mu.Unlock()
+
+    return nil
+}
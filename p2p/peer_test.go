@@ -0,0 +1,175 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/gob"
+    "net"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// waitFor polls cond for up to 5 seconds
+func waitFor(cond func() bool) bool {
+    deadline := time.Now().Add(5 * time.Second)
+    for !cond() && time.Now().Before(deadline) {
+            time.Sleep(10 * time.Millisecond)
+    }
+
+    return cond()
+}
+
+func TestPeerHandshake(t *testing.T) {
+    bc := createBlockchain(t, string(wallet.NewWallet().GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+
+    b := NewNode(Config{Listen: "localhost:0", Peers: []string{a.address}}, bc)
+    assert.Nil(t, b.Listen())
+    defer b.Close()
+
+    go a.Serve()
+    go b.Serve()
+
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 1 && len(b.Peers()) == 1 }), "Both sides complete the handshake")
+    assert.Equal(t, a.Peers()[0].RemoteAddr(), a.Peers()[0].Addr(), "Inbound peers are known by the address they connect from")
+    assert.Equal(t, b.address, a.Peers()[0].listenAddr)
+    assert.True(t, a.Peers()[0].inbound)
+    assert.Equal(t, a.address, b.Peers()[0].Addr())
+    assert.False(t, b.Peers()[0].inbound)
+    assert.Equal(t, nodeNetwork, b.Peers()[0].services)
+    assert.Equal(t, 0, b.Peers()[0].BestHeight())
+}
+
+func TestPeerTimeout(t *testing.T) {
+    defer func(interval, timeout, maintain time.Duration) {
+            pingInterval, peerTimeout, maintainInterval = interval, timeout, maintain
+    }(pingInterval, peerTimeout, maintainInterval)
+    pingInterval, peerTimeout, maintainInterval = 20*time.Millisecond, 200*time.Millisecond, 10*time.Millisecond
+
+    bc := createBlockchain(t, string(wallet.NewWallet().GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    go a.Serve()
+
+    // a peer that completes the handshake but never answers pings
+    conn, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer conn.Close()
+    writeMessage(conn, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:1"}))
+    writeMessage(conn, "verack", nil)
+
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 1 }))
+    command, _, err := readMessage(conn)
+    assert.Nil(t, err)
+    assert.Equal(t, "version", command)
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 0 }), "Silent peers are dropped")
+}
+
+func TestMalformedMessage(t *testing.T) {
+    bc := createBlockchain(t, string(wallet.NewWallet().GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    go a.Serve()
+
+    conn, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer conn.Close()
+    writeMessage(conn, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:1"}))
+    writeMessage(conn, "verack", nil)
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 1 }))
+    writeMessage(conn, "block", gobEncode(blockMsg{"localhost:1", []byte("not a block")}))
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 0 }), "The sender of a payload that can't be decoded is dropped")
+
+    other, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer other.Close()
+    writeMessage(other, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:2"}))
+    writeMessage(other, "verack", nil)
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 1 }), "The node keeps running")
+}
+
+func TestBanByConnection(t *testing.T) {
+    bc := createBlockchain(t, string(wallet.NewWallet().GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+    genesis, err := bc.GetBlock(bc.Tip())
+    assert.Nil(t, err)
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    go a.Serve()
+
+    // the sender claims to be some other node
+    conn, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer conn.Close()
+    writeMessage(conn, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:1"}))
+    writeMessage(conn, "verack", nil)
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 1 }))
+    invalid := block.NewBlock([]*transaction.Transaction{transaction.NewCoinbaseTX(string(wallet.NewWallet().GetAddress()), "", 1, 0)}, genesis.Hash, 1, genesis.Bits)
+    invalid.Nonce++
+    writeMessage(conn, "block", gobEncode(blockMsg{"localhost:1", invalid.Serialize()}))
+
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 0 }), "The sender of an invalid block is dropped")
+    assert.True(t, a.isBanned("127.0.0.1"), "The ban is on the host the connection came from")
+    assert.False(t, a.isBanned("localhost:1"), "The claimed address isn't banned")
+
+    other, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer other.Close()
+    writeMessage(other, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:2"}))
+    other.SetReadDeadline(time.Now().Add(5 * time.Second))
+    _, _, err = readMessage(other)
+    assert.NotNil(t, err, "Announcing another address doesn't get around the ban")
+}
+
+func TestReplyToRequestingPeer(t *testing.T) {
+    bc := createBlockchain(t, string(wallet.NewWallet().GetAddress()), filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    go a.Serve()
+
+    // the peer claims to be some other node, the block still comes back on its connection
+    conn, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer conn.Close()
+    writeMessage(conn, "version", gobEncode(verzion{protocolVersion, nodeNetwork, 0, "localhost:1"}))
+    writeMessage(conn, "verack", nil)
+    writeMessage(conn, "getdata", gobEncode(getdata{"localhost:1", "block", bc.Tip()}))
+
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    for {
+            command, request, err := readMessage(conn)
+            if !assert.Nil(t, err, "The block is sent to the peer that asked for it") {
+                    return
+            }
+            if command == "block" {
+                    var payload blockMsg
+                    assert.Nil(t, gob.NewDecoder(bytes.NewReader(request)).Decode(&payload))
+                    blk, err := block.DeserializeBlock(payload.Block)
+                    assert.Nil(t, err)
+                    assert.Equal(t, bc.Tip(), blk.Hash)
+                    break
+            }
+    }
+    assert.Nil(t, a.peerByAddr("localhost:1"), "The announced address isn't taken for the peer's")
+}
@@ -0,0 +1,638 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "log"
+    "net"
+    "sync"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/transaction"
+)
+
+const protocol = "tcp"
+const protocolVersion = 5    // version 2 introduced framed messages, 3 the verack and ping/pong messages, 4 headers-first sync, 5 the canonical block and transaction encoding
+const minProtocolVersion = 5 // oldest version this node still talks to, older ones send gob encoded blocks
+const commandLength = 12
+
+const banThreshold = 100 // misbehaviour score at which a node is no longer listened to
+const maxBlocksPerInv = 500 // hashes answered to a getblocks, the requester asks again for more
+
+// Node is a running network node.
+// It owns the state shared by the goroutines handling its peers,
+// so several nodes can run in one process.
+type Node struct {
+    config        Config
+    address       string // address announced to other nodes, empty for a node that doesn't listen
+    miningAddress string
+    bc            *chain.Blockchain
+    mempool       *chain.Mempool
+    ln            net.Listener
+
+    mu         sync.Mutex // guards the fields below
+    knownNodes []string   // every node we heard of, whether connected or not
+    peers      map[*Peer]bool
+    dials      map[string]*dialState
+    banScores  map[string]int // misbehaviour per host, see Peer.host
+    closing    bool
+    chainSync  *chainSync
+
+    mining sync.Mutex // held while a block is being mined
+    quit   chan struct{}
+    wg     sync.WaitGroup // peer and maintenance goroutines
+}
+
+// NewNode creates a node that knows the bootstrap peers of config at startup.
+// No node is special: each one relays transactions and blocks to its peers, and mines if given a miner address.
+func NewNode(config Config, bc *chain.Blockchain) *Node {
+    return &Node{
+            config:        config,
+            address:       config.Advertise,
+            miningAddress: config.Miner,
+            bc:            bc,
+            mempool:       chain.NewMempool(chain.DefaultMempoolSize, chain.DefaultMempoolExpiry),
+            knownNodes:    append([]string{}, config.Peers...),
+            peers:         make(map[*Peer]bool),
+            dials:         make(map[string]*dialState),
+            banScores:     make(map[string]int),
+            quit:          make(chan struct{}),
+            chainSync:     newChainSync(),
+    }
+}
+
+type verzion struct {
+    Version    int
+    Services   uint64
+    BestHeight int
+    AddrFrom   string
+}
+
+// getblocks asks for the hashes of the main chain blocks following the last block we have in common with the peer
+type getblocks struct {
+    AddrFrom string
+    Locator  [][]byte // where our main chain is, see chain.Locator
+    StopHash []byte   // last hash wanted, nil for a full batch
+}
+
+type addr struct {
+    AddrList []string
+}
+
+type inv struct {  //uses inv to show other nodes what blocks or transactions current node has. just their hashes
+    AddrFrom string
+    Type     string   //The Type field says whether these are blocks or transactions.
+    Items    [][]byte
+}
+
+//getdata is a request for certain block or transaction, and it can contain only one block/transaction ID.
+type getdata struct {
+    AddrFrom string
+    Type     string
+    ID       []byte
+}
+
+// blockMsg carries a serialized block, it isn't called block so it doesn't hide the package
+type blockMsg struct {
+    AddrFrom string
+    Block    []byte
+}
+
+type tx struct {
+    AddFrom     string
+    Transaction []byte
+}
+
+
+func commandToBytes(command string) []byte {
+    var bytes [commandLength]byte
+
+    for i, c := range command {
+        bytes[i] = byte(c)
+    }
+    return bytes[:]
+}
+
+func bytesToCommand(bytes []byte) string {
+    var command []byte
+
+    for _, b := range bytes {
+            if b != 0x0 {
+                command = append(command, b)
+            }
+    }
+    return fmt.Sprintf("%s", command)
+}
+
+
+
+
+
+func (n *Node) sendAddr(address string) {
+    nodes := addr{n.getKnownNodes()}
+    nodes.AddrList = append(nodes.AddrList, n.address)
+    payload := gobEncode(nodes)
+    n.sendMessage(address, "addr", payload)
+}
+
+func (n *Node) sendBlock(p *Peer, b *block.Block) {
+    data := blockMsg{n.address, b.Serialize()}
+    payload := gobEncode(data)
+    n.send(p, "block", payload)
+}
+
+// sendMessage queues a message for the node at addr, connecting to it first if needed
+func (n *Node) sendMessage(addr, command string, payload []byte) {
+    p := n.peerByAddr(addr)
+    if p == nil {
+            p = n.connect(addr)
+    }
+    if p == nil {
+            fmt.Printf("Couldn't send %s to %s\n", command, addr)
+            return
+    }
+    n.send(p, command, payload)
+}
+
+// send queues a message for a peer. Answers go to the peer that asked, never to the
+// address a message claims to come from, which the sender is free to make up.
+func (n *Node) send(p *Peer, command string, payload []byte) {
+    if !p.queue(command, payload) {
+            fmt.Printf("Couldn't send %s to %s\n", command, p.RemoteAddr())
+    }
+}
+
+func (n *Node) sendInv(p *Peer, kind string, items [][]byte) {
+    inventory := inv{n.address, kind, items}
+    payload := gobEncode(inventory)
+    n.send(p, "inv", payload)
+}
+
+func (n *Node) sendGetData(p *Peer, kind string, id []byte) {
+    payload := gobEncode(getdata{n.address, kind, id})
+    n.send(p, "getdata", payload)
+}
+
+func (n *Node) txPayload(tnx *transaction.Transaction) []byte {
+    return gobEncode(tx{n.address, tnx.Serialize()})
+}
+
+// SendTx sends a transaction to the node at addr
+func (n *Node) SendTx(addr string, tnx *transaction.Transaction) {
+    n.sendMessage(addr, "tx", n.txPayload(tnx))
+}
+
+func (n *Node) handleAddr(request []byte) error {
+    var buff bytes.Buffer
+    var payload addr
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+        
+    for _, node := range payload.AddrList {
+            n.addNode(node)
+    }
+    fmt.Printf("There are %d known nodes now!\n", len(n.getKnownNodes()))
+
+    return nil
+}
+
+
+
+// it requests a list of block hashes. This is done to reduce network load, because blocks can be downloaded from different nodes, and we don’t want to download dozens of gigabytes from one node.
+// Only the hashes after the fork point with the requester's chain are sent, at most maxBlocksPerInv of them.
+func (n *Node) handleGetBlocks(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload getblocks
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+    blocks := n.bc.MainChainAfter(payload.Locator, payload.StopHash, maxBlocksPerInv)
+    if len(blocks) == 0 {
+        return nil
+    }
+    n.sendInv(p, "block", blocks)
+
+    return nil
+}
+
+
+func (n *Node) handleInv(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload inv
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+    fmt.Printf("Recevied inventory with %d %s\n", len(payload.Items), payload.Type)
+    if len(payload.Items) == 0 {
+            return nil
+    }
+    if payload.Type == "block" {
+            // blocks are announced by every peer that gets them, most of them we'll have already
+            var unknown [][]byte
+            for _, hash := range payload.Items {
+                    if _, err := n.bc.GetBlock(hash); err != nil {
+                            unknown = append(unknown, hash)
+                    }
+            }
+            if len(unknown) == 0 {
+                    return nil
+            }
+            err := n.requestHeaders(p) // fetch the headers first, the blocks follow from them
+            if err != nil {
+                    fmt.Printf("Can't ask %s for headers: %s\n", p.conn.RemoteAddr(), err)
+            }
+            return nil
+    }
+    if payload.Type == "tx" {
+        txID := payload.Items[0] //we’ll never send inv with multiple hashes. That’s why only the first hash is taken
+    
+        if !n.mempool.Has(txID) {
+                n.sendGetData(p, "tx", txID)
+        }
+    }
+
+    return nil
+}
+
+// handleGetData sends the block or the mempool transaction asked for, if we have it
+func (n *Node) handleGetData(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload getdata
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+    if payload.Type == "block" {
+        block, err := n.bc.GetBlock([]byte(payload.ID))
+        if err != nil {
+            fmt.Printf("%s asked for block %x: %s\n", p.RemoteAddr(), payload.ID, err)
+            return nil
+        } 
+        n.sendBlock(p, &block)
+    }
+            
+    if payload.Type == "tx" {
+        tx, ok := n.mempool.Get(payload.ID)
+        if !ok {
+            return nil
+        }
+                        
+        n.send(p, "tx", n.txPayload(tx))
+    }
+
+    return nil
+}
+// handleBlock validates a received block before adding it; senders of invalid blocks get banned
+func (n *Node) handleBlock(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload blockMsg
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+
+    if n.isBanned(p.host()) {
+        return nil
+    }
+
+    blockData := payload.Block
+    block, err := block.DeserializeBlock(blockData)
+    if err != nil {
+        return err
+    }
+
+    fmt.Println("Recevied a new block!")
+    connected, disconnected, err := n.bc.AddBlock(block) // AddBlock validates the block and keeps the UTXO set in step with the main chain
+    var blockErr *chain.BlockError
+    if errors.As(err, &blockErr) {
+        fmt.Printf("Rejected block from %s: %s\n", p.conn.RemoteAddr(), err)
+        if !blockErr.Excusable() {
+            n.penalize(p, banThreshold)
+        }
+        n.blockReceived(block, false)
+        return nil
+    }
+    if err != nil { // our own failure, the request times out and the block is asked for again
+        fmt.Printf("Can't add block %x: %s\n", block.Hash, err)
+        return nil
+    }
+    n.updateMempool(connected, disconnected)
+    n.blockReceived(block, true)
+
+    fmt.Printf("Added block %x\n", block.Hash)
+    p.mu.Lock()
+    if block.Height > p.bestHeight {
+        p.bestHeight = block.Height
+    }
+    p.mu.Unlock()
+
+    if len(connected) > 0 {
+            n.relayBlock(connected[len(connected)-1], p)
+    }
+
+    return nil
+}
+
+// relayBlock announces a new tip to the peers that don't have it yet, except the one it came from
+func (n *Node) relayBlock(block *block.Block, from *Peer) {
+    for _, p := range n.Peers() {
+            if p != from && p.BestHeight() < block.Height {
+                    n.sendInv(p, "block", [][]byte{block.Hash})
+            }
+    }
+}
+
+// updateMempool drops transactions that made it into the main chain, along with
+// those conflicting with them, and offers back the ones from blocks that were
+// reorganized away. Those are checked against the new UTXO set like any other.
+func (n *Node) updateMempool(connected, disconnected []*block.Block) {
+    for _, block := range connected {
+            n.mempool.RemoveBlock(block)
+    }
+    for _, block := range disconnected {
+            for _, tx := range block.Transactions {
+                    if !tx.IsCoinbase() {
+                            n.mempool.Add(tx, n.bc)
+                    }
+            }
+    }
+}
+
+func (n *Node) handleTx(p *Peer, request []byte) error {
+    var buff bytes.Buffer
+    var payload tx
+
+    buff.Write(request)
+    dec := gob.NewDecoder(&buff)
+    err := dec.Decode(&payload)
+    if err != nil {
+        return err
+    }
+
+    txData := payload.Transaction
+    tx, err := transaction.DeserializeTransaction(txData)
+    if err != nil {
+        return err
+    }
+    err = n.acceptTx(&tx, p)
+    if err != nil {
+        fmt.Println(err)
+    }
+
+    return nil
+}
+
+// SubmitTx puts a transaction made on this node in the mempool and announces it to the peers.
+// It fails when the mempool doesn't take the transaction.
+func (n *Node) SubmitTx(tx *transaction.Transaction) error {
+    return n.acceptTx(tx, nil)
+}
+
+// Mempool returns the transactions waiting to be mined
+func (n *Node) Mempool() *chain.Mempool {
+    return n.mempool
+}
+
+// acceptTx adds a transaction to the mempool, relays it to the peers but the one it came from
+// and mines once there are enough transactions
+func (n *Node) acceptTx(tx *transaction.Transaction, from *Peer) error {
+    err := n.mempool.Add(tx, n.bc) //to put new transaction in the mempool, if it is valid
+    if err != nil {
+        return err
+    }
+
+    for _, p := range n.Peers() { // every node forwards new transactions to its other peers
+        if p != from {
+            n.sendInv(p, "tx", [][]byte{tx.ID})
+        }
+    }
+    if n.mempool.Count() >= 2 && len(n.miningAddress) > 0 { //When there are 2 or more transactions in the mempool of the current (miner) node, mining begins.
+        n.mineTransactions()
+    }
+
+    return nil
+}
+
+// mineTransactions mines blocks until the mempool is empty.
+// Only one connection mines at a time, the others leave their transactions to it.
+func (n *Node) mineTransactions() {
+    if !n.mining.TryLock() {
+        return
+    }
+    defer n.mining.Unlock()
+
+    for n.mempool.Count() > 0 {
+        txs, fees := n.mempool.BlockTemplate() // transactions were validated on entry, best fee rates first
+        if len(txs) == 0 {
+            fmt.Println("No transactions to mine! Waiting for new ones...")
+            return
+        }
+        coinbase := func(height int) *transaction.Transaction { // the miner collects the fees of every transaction it includes
+            return transaction.NewCoinbaseTX(n.miningAddress, "", height, fees)
+        }
+        //Verified transactions are being put into a block,as well as a coinbase transaction with the reward
+        newBlock, connected, disconnected, err := n.bc.MineBlock(txs, coinbase) // the UTXO set is updated along with the block
+        if err != nil {
+            fmt.Printf("Mining failed: %s\n", err) // a block arriving meanwhile may have spent the same outputs
+            return
+        }
+        fmt.Println("New block is mined!")
+// After a transaction is mined, it’s removed from the mempool, once its block is on the main chain.
+        n.updateMempool(connected, disconnected)
+//Every peer of the current node receives inv message with the new block’s hash. They can request the block after handling the message.
+        n.relayBlock(newBlock, nil)
+    }
+}
+
+// Listen opens the node's listening socket on the configured host:port.
+// Unless an address to advertise is configured, the node announces the one it listens on,
+// which with port 0 is the free port the system picked.
+func (n *Node) Listen() error {
+    ln, err := net.Listen(protocol, n.config.Listen)
+    if err != nil {
+        return err
+    }
+    n.ln = ln
+    if n.address == "" {
+        n.address = ln.Addr().String()
+    }
+
+    return nil
+}
+
+// Serve connects to the known nodes and accepts peers until the listener is closed
+func (n *Node) Serve() {
+    n.wg.Add(1)
+    go n.maintainPeers() // dials the bootstrap peers right away
+
+    for {
+        conn, err := n.ln.Accept()
+        if err != nil {
+            return
+        } 
+        n.accept(conn)
+    }
+}
+
+// Close stops the node: the listener is closed and every peer disconnected
+// once its queued messages are written
+func (n *Node) Close() error {
+    n.mu.Lock()
+    if n.closing {
+        n.mu.Unlock()
+        return nil
+    }
+    n.closing = true
+    var peers []*Peer
+    for p := range n.peers {
+        peers = append(peers, p)
+    }
+    n.mu.Unlock()
+
+    close(n.quit)
+    var err error
+    if n.ln != nil {
+        err = n.ln.Close()
+    }
+    for _, p := range peers {
+        p.disconnect()
+    }
+    n.wg.Wait()
+
+    return err
+}
+
+// handleMessage passes a message to its handler. An error means the payload couldn't be processed.
+func (n *Node) handleMessage(p *Peer, command string, request []byte) error {
+    switch command {
+        case "addr":
+            return n.handleAddr(request)
+        case "block":
+            return n.handleBlock(p, request)
+        case "inv":
+            return n.handleInv(p, request)
+        case "getheaders":
+            return n.handleGetHeaders(p, request)
+        case "headers":
+            return n.handleHeaders(p, request)
+        case "getblocks":
+            return n.handleGetBlocks(p, request)
+        case "getdata":
+            return n.handleGetData(p, request)
+        case "tx":
+            return n.handleTx(p, request)
+        case "version":
+            return n.handleVersion(p, request)
+        case "verack":
+            n.handleVerack(p)
+        case "ping":
+            return n.handlePing(p, request)
+        case "pong":
+            return n.handlePong(p, request)
+        default:
+            fmt.Println("Unknown command!")
+    }
+    return nil
+}
+
+func gobEncode(data interface{}) []byte {
+    var buff bytes.Buffer
+
+    enc := gob.NewEncoder(&buff)
+    err := enc.Encode(data)
+    if err != nil {
+        log.Panic(err)
+    }
+        
+    return buff.Bytes()
+}
+
+// penalize raises the misbehaviour score of the host a peer connects from; once it
+// reaches banThreshold the connections from that host are dropped and refused, and
+// the nodes we know there are forgotten
+func (n *Node) penalize(p *Peer, score int) {
+    host := p.host()
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    n.banScores[host] += score
+    if n.banScores[host] < banThreshold {
+            return
+    }
+
+    fmt.Printf("Banning %s\n", host)
+    for _, addr := range append([]string{}, n.knownNodes...) {
+            if hostOf(addr) == host {
+                    n.removeNode(addr)
+            }
+    }
+    for peer := range n.peers {
+            if peer.host() == host {
+                    delete(n.peers, peer)
+                    peer.disconnect()
+            }
+    }
+}
+
+func (n *Node) isBanned(host string) bool {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    return n.banScores[host] >= banThreshold
+}
+
+// getKnownNodes returns a copy of the known nodes, so it can be ranged over while they change
+func (n *Node) getKnownNodes() []string {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    return append([]string{}, n.knownNodes...)
+}
+
+func (n *Node) addNode(addr string) {
+    n.mu.Lock()
+    defer n.mu.Unlock()
+
+    if addr == "" || addr == n.address || n.banScores[hostOf(addr)] >= banThreshold {
+            return
+    }
+    for _, node := range n.knownNodes {
+            if node == addr {
+                    return
+            }
+    }
+    n.knownNodes = append(n.knownNodes, addr)
+}
+
+// removeNode drops addr from the known nodes; n.mu must be held
+func (n *Node) removeNode(addr string) {
+    var updatedNodes []string
+    for _, node := range n.knownNodes {
+            if node != addr {
+                    updatedNodes = append(updatedNodes, node)
+            }
+    }
+    n.knownNodes = updatedNodes
+}
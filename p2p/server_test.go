@@ -0,0 +1,91 @@
+package p2p
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// createBlockchain, newBlockchain and mineBlock stop the test when the chain can't be opened or the block added
+func createBlockchain(t *testing.T, address, dbFile string) *chain.Blockchain {
+    bc, err := chain.CreateBlockchainFile(dbFile, address)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return bc
+}
+
+func newBlockchain(t *testing.T, dbFile string) *chain.Blockchain {
+    bc, err := chain.NewBlockchainFile(dbFile)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return bc
+}
+
+func mineBlock(t *testing.T, bc *chain.Blockchain, txs []*transaction.Transaction) *block.Block {
+    block, _, _, err := bc.MineBlock(txs, nil)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return block
+}
+
+func TestNodesSync(t *testing.T) {
+    // all nodes start from the same genesis block
+    miner := string(wallet.NewWallet().GetAddress())
+    dir := t.TempDir()
+    genesis := filepath.Join(dir, "genesis.db")
+    bc := createBlockchain(t, miner, genesis)
+    bc.Close()
+    data, err := ioutil.ReadFile(genesis)
+    assert.Nil(t, err)
+
+    chains := make(map[string]*chain.Blockchain)
+    for _, name := range []string{"a", "b", "c"} {
+            file := filepath.Join(dir, name+".db")
+            assert.Nil(t, ioutil.WriteFile(file, data, 0600))
+            chains[name] = newBlockchain(t, file)
+            defer chains[name].Close()
+    }
+    bcA, bcB, bcC := chains["a"], chains["b"], chains["c"]
+
+    for i := 1; i <= 3; i++ {
+            mineBlock(t, bcA, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)})
+    }
+
+    // a line of nodes, c only knows b at startup
+    a := NewNode(Config{Listen: "localhost:0"}, bcA)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    b := NewNode(Config{Listen: "localhost:0", Peers: []string{a.address}}, bcB)
+    assert.Nil(t, b.Listen())
+    defer b.Close()
+    c := NewNode(Config{Listen: "localhost:0", Peers: []string{b.address}}, bcC)
+    assert.Nil(t, c.Listen())
+    defer c.Close()
+
+    go a.Serve()
+    go b.Serve()
+    go c.Serve()
+
+    synced := func(height int) bool {
+            return bcB.GetBestHeight() == height && bcC.GetBestHeight() == height
+    }
+    assert.True(t, waitFor(func() bool { return synced(3) }), "New nodes catch up")
+    assert.Equal(t, bcA.Tip(), bcB.Tip())
+    assert.Equal(t, bcA.Tip(), bcC.Tip())
+    assert.True(t, waitFor(func() bool { return len(a.Peers()) == 2 }), "Nodes learn about each other from their peers")
+
+    block := mineBlock(t, bcA, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", 4, 0)})
+    a.relayBlock(block, nil)
+    assert.True(t, waitFor(func() bool { return synced(4) }), "New blocks are relayed")
+    assert.Equal(t, block.Hash, bcC.Tip())
+}
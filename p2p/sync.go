@@ -0,0 +1,438 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "math/big"
+    "sync"
+    "time"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+)
+
+const maxHeadersPerMessage = 2000
+const maxBlocksInFlightPerPeer = 16
+const downloadWindow = 1024        // blocks are only requested this far ahead of the first missing one
+
+// vars rather than consts so tests can speed them up
+var headersTimeout = time.Minute
+var blockDownloadTimeout = 30 * time.Second
+var maxSyncHeaders = 50000 // headers kept while their blocks are missing, a few MB
+
+type getheaders struct {
+    AddrFrom string
+    Locator  [][]byte // where our best chain is, see chain.Locator
+    StopHash []byte   // last header wanted, nil for as many as fit in a message
+}
+
+type headers struct {
+    AddrFrom string
+    Headers  []block.Header
+}
+
+// headerNode is a header whose place in the chain is known
+type headerNode struct {
+    header block.Header
+    hash   []byte
+    height int
+    work   *big.Int // cumulative work of the branch ending here
+}
+
+type blockRequest struct {
+    peer *Peer
+    sent time.Time
+}
+
+// chainSync drives headers-first synchronization. The header chain is downloaded
+// and validated from one peer first; the blocks it is missing are then fetched in
+// parallel from every peer that has them, a window at a time, and requests that
+// time out are sent again to another peer.
+type chainSync struct {
+    mu          sync.Mutex
+    headers     map[string]*headerNode // validated headers of blocks we're still missing, at most about maxSyncHeaders
+    bestHeader  *headerNode            // nil until a header chain with more work than our tip shows up
+    headersPeer *Peer                  // peer the header chain is being downloaded from
+    headersSent time.Time
+    queue       []*headerNode          // missing blocks, in chain order
+    inFlight    map[string]*blockRequest
+}
+
+func newChainSync() *chainSync {
+    return &chainSync{
+            headers:  make(map[string]*headerNode),
+            inFlight: make(map[string]*blockRequest),
+    }
+}
+
+// reset forgets the header chain, after it led to an invalid block
+func (cs *chainSync) reset() {
+    cs.headers = make(map[string]*headerNode)
+    cs.bestHeader = nil
+    cs.headersPeer = nil
+    cs.queue = nil
+    cs.inFlight = make(map[string]*blockRequest)
+}
+
+// lookupHeader finds a header among the downloaded ones or the stored blocks; n.chainSync.mu must be held
+func (n *Node) lookupHeader(hash []byte) *headerNode {
+    if node := n.chainSync.headers[hex.EncodeToString(hash)]; node != nil {
+            return node
+    }
+
+    block, err := n.bc.GetBlock(hash)
+    if err != nil {
+            return nil
+    }
+    work := n.bc.GetChainWork(hash)
+    if work == nil { // an orphan, we don't know what's behind it
+            return nil
+    }
+
+    return &headerNode{block.Header, block.Hash, block.Height, work}
+}
+
+// bestHeaderTx returns the best of the header chain and the main chain; n.chainSync.mu must be held
+func (n *Node) bestHeaderTx() *headerNode {
+    tip := n.lookupHeader(n.bc.Tip())
+    if best := n.chainSync.bestHeader; best != nil && best.work.Cmp(tip.work) > 0 {
+            return best
+    }
+
+    return tip
+}
+
+// requestHeaders asks p for the headers following our best header
+func (n *Node) requestHeaders(p *Peer) error {
+    cs := n.chainSync
+    cs.mu.Lock()
+    defer cs.mu.Unlock()
+
+    var missing []byte
+    locator := chain.Locator(n.bestHeaderTx().hash, func(hash []byte) []byte {
+            node := n.lookupHeader(hash)
+            if node == nil { // dropped from the header chain since, by a reset
+                    missing = hash
+                    return nil
+            }
+            return node.header.PrevBlockHash
+    })
+    if missing != nil {
+            return fmt.Errorf("can't build a locator, header %x is not known", missing)
+    }
+    cs.headersPeer = p
+    cs.headersSent = time.Now()
+    p.queue("getheaders", gobEncode(getheaders{n.address, locator, nil}))
+
+    return nil
+}
+
+// startSync downloads the header chain from p if it is ahead of us and no other peer is already sending headers
+func (n *Node) startSync(p *Peer) {
+    cs := n.chainSync
+    cs.mu.Lock()
+    ahead := p.BestHeight() > n.bestHeaderTx().height
+    busy := cs.headersPeer != nil
+    cs.mu.Unlock()
+
+    if ahead && !busy {
+            err := n.requestHeaders(p)
+            if err != nil {
+                    fmt.Printf("Can't sync with %s: %s\n", p.conn.RemoteAddr(), err)
+            }
+    }
+}
+
+func (n *Node) handleGetHeaders(p *Peer, request []byte) error {
+    var payload getheaders
+
+    err := gob.NewDecoder(bytes.NewReader(request)).Decode(&payload)
+    if err != nil {
+        return err
+    }
+
+    var result []block.Header
+    for _, hash := range n.bc.MainChainAfter(payload.Locator, payload.StopHash, maxHeadersPerMessage) {
+            block, err := n.bc.GetBlock(hash)
+            if err != nil {
+                    return err
+            }
+            result = append(result, block.Header)
+    }
+    p.queue("headers", gobEncode(headers{n.address, result}))
+
+    return nil
+}
+
+// handleHeaders validates a batch of headers and extends the header chain with them.
+// A full batch means the peer has more, so the next one is requested right away.
+// Once the header chain is complete the missing blocks are scheduled for download.
+func (n *Node) handleHeaders(p *Peer, request []byte) error {
+    var payload headers
+
+    err := gob.NewDecoder(bytes.NewReader(request)).Decode(&payload)
+    if err != nil {
+        return err
+    }
+
+    cs := n.chainSync
+    cs.mu.Lock()
+    tip := n.lookupHeader(n.bc.Tip())
+    var last *headerNode
+    for _, header := range payload.Headers {
+            node, err := n.checkHeader(header)
+            if err != nil {
+                    cs.headersPeer = nil
+                    cs.mu.Unlock()
+                    fmt.Printf("Rejected headers from %s: %s\n", payload.AddrFrom, err)
+                    var blockErr *chain.BlockError
+                    if errors.As(err, &blockErr) && !blockErr.Excusable() { // we may have reorganized since asking
+                            n.penalize(p, banThreshold)
+                    }
+                    return nil
+            }
+            last = node
+            // the work counts from genesis, so headers of blocks we already have count too
+            if node.work.Cmp(tip.work) > 0 && (cs.bestHeader == nil || node.work.Cmp(cs.bestHeader.work) > 0) {
+                    cs.bestHeader = node
+            }
+    }
+    if cs.headersPeer == p {
+            cs.headersPeer = nil
+    }
+    full := false
+    if len(cs.headers) > maxSyncHeaders {
+            n.pruneHeaders(last)
+            full = len(cs.headers) >= maxSyncHeaders
+    }
+    cs.mu.Unlock()
+
+    if last != nil {
+            p.mu.Lock()
+            if last.height > p.bestHeight {
+                    p.bestHeight = last.height
+            }
+            p.mu.Unlock()
+    }
+    // once there's no room left, the rest of the headers is asked for when the blocks are in
+    if len(payload.Headers) == maxHeadersPerMessage && !full {
+            err := n.requestHeaders(p)
+            if err != nil {
+                    fmt.Printf("Can't ask %s for more headers: %s\n", p.conn.RemoteAddr(), err)
+            }
+    }
+
+    n.scheduleDownloads()
+
+    return nil
+}
+
+// checkHeader validates a header against its parent and indexes it; n.chainSync.mu must be held.
+// The header must link to a known header and carry the proof-of-work and the
+// difficulty the chain requires at its height, as the block will have to.
+func (n *Node) checkHeader(header block.Header) (*headerNode, error) {
+    hash := header.Hash()
+    if known := n.lookupHeader(hash); known != nil {
+            return known, nil
+    }
+
+    parent := n.lookupHeader(header.PrevBlockHash)
+    if parent == nil {
+            return nil, rejectHeader(hash, chain.RejectUnknownParent, "parent %x is not known", header.PrevBlockHash)
+    }
+    pow := block.NewProofOfWork(&block.Block{Header: header, Hash: hash})
+    if !pow.Validate() {
+            return nil, rejectHeader(hash, chain.RejectBadPoW, "hash is above the target")
+    }
+    bits, err := n.expectedBits(parent)
+    if err != nil {
+            return nil, err
+    }
+    if header.Bits != bits {
+            return nil, rejectHeader(hash, chain.RejectBadDifficulty, "bits %08x don't match the expected %08x", header.Bits, bits)
+    }
+    median, err := n.medianTimePast(parent)
+    if err != nil {
+            return nil, err
+    }
+    if header.Timestamp <= median {
+            return nil, rejectHeader(hash, chain.RejectTimeTooOld, "timestamp %d is not past the median time %d of the headers before it", header.Timestamp, median)
+    }
+    if header.Timestamp > time.Now().Unix()+block.MaxFutureBlockTime {
+            return nil, rejectHeader(hash, chain.RejectTimeTooNew, "timestamp %d is more than %d seconds ahead of our clock", header.Timestamp, block.MaxFutureBlockTime)
+    }
+
+    node := &headerNode{header, hash, parent.height + 1, new(big.Int).Add(pow.Work(), parent.work)}
+    n.chainSync.headers[hex.EncodeToString(node.hash)] = node
+
+    return node, nil
+}
+
+// rejectHeader is the *chain.BlockError for a header breaking a consensus rule
+func rejectHeader(hash []byte, reason chain.RejectReason, format string, a ...interface{}) *chain.BlockError {
+    return &chain.BlockError{Hash: hash, Reason: reason, Detail: fmt.Sprintf(format, a...)}
+}
+
+// expectedBits mirrors the chain's nextBits for headers; n.chainSync.mu must be held.
+// It fails if a header of the retarget period is no longer known.
+func (n *Node) expectedBits(parent *headerNode) (uint32, error) {
+    if (parent.height+1)%block.RetargetInterval != 0 {
+            return parent.header.Bits, nil
+    }
+
+    first := parent
+    for i := 0; i < block.RetargetInterval-1; i++ {
+            prev := n.lookupHeader(first.header.PrevBlockHash)
+            if prev == nil {
+                    return 0, fmt.Errorf("can't retarget, header %x is not known", first.header.PrevBlockHash)
+            }
+            first = prev
+    }
+
+    return block.CalcNextBits(parent.header.Bits, parent.header.Timestamp-first.header.Timestamp), nil
+}
+
+// pruneHeaders keeps only the headers leading to the best header and to last, the end
+// of the chain the headers peer is sending, which may get ahead of us later on.
+// Headers of blocks that are connected are dropped, they're looked up from the chain.
+// n.chainSync.mu must be held.
+func (n *Node) pruneHeaders(last *headerNode) {
+    cs := n.chainSync
+    kept := make(map[string]*headerNode)
+    for _, node := range []*headerNode{cs.bestHeader, last} {
+            for node != nil && n.bc.GetChainWork(node.hash) == nil {
+                    kept[hex.EncodeToString(node.hash)] = node
+                    node = cs.headers[hex.EncodeToString(node.header.PrevBlockHash)]
+            }
+    }
+    cs.headers = kept
+}
+
+// medianTimePast mirrors the chain's medianTimePast for headers; n.chainSync.mu must be held
+func (n *Node) medianTimePast(node *headerNode) (int64, error) {
+    var timestamps []int64
+    for i := 0; i < block.MedianTimeBlocks; i++ {
+            timestamps = append(timestamps, node.header.Timestamp)
+            if len(node.header.PrevBlockHash) == 0 {
+                    break
+            }
+            prev := n.lookupHeader(node.header.PrevBlockHash)
+            if prev == nil {
+                    return 0, fmt.Errorf("can't find the median time, header %x is not known", node.header.PrevBlockHash)
+            }
+            node = prev
+    }
+
+    return block.MedianTime(timestamps), nil
+}
+
+// scheduleDownloads queues the blocks of the best header chain we don't have and requests them.
+// Nothing is downloaded unless the header chain has more work than our tip.
+func (n *Node) scheduleDownloads() {
+    cs := n.chainSync
+    cs.mu.Lock()
+
+    if cs.bestHeader != nil && cs.bestHeader.work.Cmp(n.lookupHeader(n.bc.Tip()).work) <= 0 {
+            cs.bestHeader = nil
+    }
+    var missing []*headerNode
+    for node := cs.bestHeader; node != nil && !n.bc.HasBlock(node.hash); {
+            missing = append(missing, node)
+            node = cs.headers[hex.EncodeToString(node.header.PrevBlockHash)]
+    }
+    cs.queue = nil
+    for i := len(missing) - 1; i >= 0; i-- {
+            cs.queue = append(cs.queue, missing[i])
+    }
+    cs.mu.Unlock()
+
+    n.requestBlocks()
+}
+
+// requestBlocks spreads requests for the queued blocks over the peers that have them,
+// keeping at most maxBlocksInFlightPerPeer in flight with each
+func (n *Node) requestBlocks() {
+    peers := n.Peers()
+
+    cs := n.chainSync
+    cs.mu.Lock()
+    defer cs.mu.Unlock()
+
+    load := make(map[*Peer]int)
+    for _, request := range cs.inFlight {
+            load[request.peer]++
+    }
+    for i, node := range cs.queue {
+            if i >= downloadWindow {
+                    break
+            }
+            hash := hex.EncodeToString(node.hash)
+            if cs.inFlight[hash] != nil {
+                    continue
+            }
+
+            var best *Peer
+            for _, p := range peers {
+                    if p.BestHeight() >= node.height && load[p] < maxBlocksInFlightPerPeer && (best == nil || load[p] < load[best]) {
+                            best = p
+                    }
+            }
+            if best == nil {
+                    continue
+            }
+            if best.queue("getdata", gobEncode(getdata{n.address, "block", node.hash})) {
+                    cs.inFlight[hash] = &blockRequest{best, time.Now()}
+                    load[best]++
+            }
+    }
+}
+
+// blockReceived takes a downloaded block off the queue and requests more.
+// When the block was invalid the header chain leading to it is dropped.
+func (n *Node) blockReceived(block *block.Block, valid bool) {
+    cs := n.chainSync
+    cs.mu.Lock()
+    hash := hex.EncodeToString(block.Hash)
+    delete(cs.inFlight, hash)
+    for i, node := range cs.queue {
+            if bytes.Equal(node.hash, block.Hash) {
+                    cs.queue = append(cs.queue[:i], cs.queue[i+1:]...)
+                    break
+            }
+    }
+    if !valid && cs.headers[hash] != nil {
+            cs.reset()
+    }
+    if len(cs.queue) == 0 && len(cs.inFlight) == 0 {
+            // everything is stored, the headers can be looked up from the blocks
+            cs.headers = make(map[string]*headerNode)
+            cs.bestHeader = nil
+    }
+    cs.mu.Unlock()
+
+    n.requestBlocks()
+}
+
+// checkSync gives up on requests that took too long, so they are sent to other peers
+func (n *Node) checkSync() {
+    cs := n.chainSync
+    cs.mu.Lock()
+    for hash, request := range cs.inFlight {
+            if request.peer.isClosed() || time.Since(request.sent) > blockDownloadTimeout {
+                    fmt.Printf("Block %s from %s timed out\n", hash, request.peer.conn.RemoteAddr())
+                    delete(cs.inFlight, hash)
+            }
+    }
+    if cs.headersPeer != nil && (cs.headersPeer.isClosed() || time.Since(cs.headersSent) > headersTimeout) {
+            cs.headersPeer = nil
+    }
+    cs.mu.Unlock()
+
+    for _, p := range n.Peers() {
+            n.startSync(p)
+    }
+    n.requestBlocks()
+}
@@ -0,0 +1,173 @@
+package p2p
+
+import (
+    "bytes"
+    "encoding/gob"
+    "encoding/hex"
+    "net"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+func TestHeadersSync(t *testing.T) {
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    var blocks []*block.Block
+    for i := 1; i <= 12; i++ {
+            blocks = append(blocks, mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)}))
+    }
+
+    locator := [][]byte{blocks[4].Hash, []byte("unknown"), blocks[1].Hash}
+    assert.Equal(t, [][]byte{blocks[5].Hash, blocks[6].Hash}, bc.MainChainAfter(locator, nil, 2), "Hashes start after the last common block")
+    assert.Equal(t, [][]byte{blocks[5].Hash}, bc.MainChainAfter(locator, blocks[5].Hash, 10), "Hashes end at the stop hash")
+    assert.Empty(t, bc.MainChainAfter([][]byte{bc.Tip()}, nil, 10))
+
+    n := NewNode(Config{}, bc)
+    n.chainSync.mu.Lock()
+    defer n.chainSync.mu.Unlock()
+
+    header := blocks[11].Header
+    node, err := n.checkHeader(header)
+    assert.Nil(t, err)
+    assert.Equal(t, 12, node.height, "Known headers are looked up from the blocks")
+
+    header.Bits = blocks[10].Bits // the difficulty was retargeted at height 10
+    header.PrevBlockHash = blocks[10].Hash
+    for header.Nonce = 0; !block.NewProofOfWork(&block.Block{Header: header, Hash: header.Hash()}).Validate(); header.Nonce++ {
+    }
+    node, err = n.checkHeader(header)
+    assert.Nil(t, err, "A sibling of the tip is a valid header")
+    assert.Equal(t, 12, node.height)
+
+    old := header
+    old.Timestamp = blocks[0].Timestamp
+    for old.Nonce = 0; !block.NewProofOfWork(&block.Block{Header: old, Hash: old.Hash()}).Validate(); old.Nonce++ {
+    }
+    _, err = n.checkHeader(old)
+    assert.Equal(t, chain.RejectTimeTooOld, err.(*chain.BlockError).Reason, "Headers must be past the median time")
+    future := header
+    future.Timestamp = time.Now().Unix() + block.MaxFutureBlockTime + 60
+    for future.Nonce = 0; !block.NewProofOfWork(&block.Block{Header: future, Hash: future.Hash()}).Validate(); future.Nonce++ {
+    }
+    _, err = n.checkHeader(future)
+    assert.Equal(t, chain.RejectTimeTooNew, err.(*chain.BlockError).Reason)
+
+    header.PrevBlockHash = node.hash
+    header.Bits = block.BigToCompact(block.PowLimit)
+    for header.Nonce = 0; block.NewProofOfWork(&block.Block{Header: header, Hash: header.Hash()}).Validate(); header.Nonce++ {
+    }
+    _, err = n.checkHeader(header)
+    assert.Equal(t, chain.RejectBadPoW, err.(*chain.BlockError).Reason)
+    for header.Nonce = 0; !block.NewProofOfWork(&block.Block{Header: header, Hash: header.Hash()}).Validate(); header.Nonce++ {
+    }
+    _, err = n.checkHeader(header)
+    assert.Equal(t, chain.RejectBadDifficulty, err.(*chain.BlockError).Reason, "Headers must keep the difficulty")
+
+    header.PrevBlockHash = []byte("unknown")
+    _, err = n.checkHeader(header)
+    assert.Equal(t, chain.RejectUnknownParent, err.(*chain.BlockError).Reason)
+}
+
+func TestGetBlocks(t *testing.T) {
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+
+    genesis := bc.Tip()
+    var blocks []*block.Block
+    for i := 1; i <= 5; i++ {
+            blocks = append(blocks, mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)}))
+    }
+
+    a := NewNode(Config{Listen: "localhost:0"}, bc)
+    assert.Nil(t, a.Listen())
+    defer a.Close()
+    go a.Serve()
+
+    // a peer that has the first three blocks and asks for the rest with getblocks
+    conn, err := net.Dial(protocol, a.address)
+    assert.Nil(t, err)
+    defer conn.Close()
+    writeMessage(conn, "version", gobEncode(verzion{minProtocolVersion, nodeNetwork, 3, "localhost:1"}))
+    writeMessage(conn, "verack", nil)
+    locator := [][]byte{blocks[2].Hash, blocks[1].Hash, blocks[0].Hash, genesis}
+    writeMessage(conn, "getblocks", gobEncode(getblocks{"localhost:1", locator, nil}))
+
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+    for {
+            command, payload, err := readMessage(conn)
+            if !assert.Nil(t, err) {
+                    return
+            }
+            if command == "inv" {
+                    var received inv
+                    assert.Nil(t, gob.NewDecoder(bytes.NewReader(payload)).Decode(&received))
+                    assert.Equal(t, [][]byte{blocks[3].Hash, blocks[4].Hash}, received.Items, "Only the blocks after the locator are announced")
+                    return
+            }
+    }
+}
+
+// forkHeaders mines count headers on top of parent, with the genesis difficulty
+func forkHeaders(parent *block.Block, count int, seed int64) []block.Header {
+    var result []block.Header
+    prev := parent.Hash
+    for i := 0; i < count; i++ {
+            header := block.Header{PrevBlockHash: prev, MerkleRoot: parent.MerkleRoot, Timestamp: parent.Timestamp + seed + int64(i), Bits: parent.Bits}
+            for ; !block.NewProofOfWork(&block.Block{Header: header, Hash: header.Hash()}).Validate(); header.Nonce++ {
+            }
+            result = append(result, header)
+            prev = header.Hash()
+    }
+
+    return result
+}
+
+func TestHeadersWork(t *testing.T) {
+    defer func(max int) { maxSyncHeaders = max }(maxSyncHeaders)
+
+    miner := string(wallet.NewWallet().GetAddress())
+    bc := createBlockchain(t, miner, filepath.Join(t.TempDir(), "blockchain.db"))
+    defer bc.Close()
+    genesis, err := bc.GetBlock(bc.Tip())
+    assert.Nil(t, err)
+    for i := 1; i <= 3; i++ {
+            mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(miner, "", i, 0)})
+    }
+
+    n := NewNode(Config{}, bc)
+    conn, other := net.Pipe()
+    defer conn.Close()
+    defer other.Close()
+    p := newPeer(conn, false)
+
+    fork := forkHeaders(&genesis, 4, 1)
+    assert.Nil(t, n.handleHeaders(p, gobEncode(headers{"", fork[:3]})))
+    assert.Nil(t, n.chainSync.bestHeader, "A header chain with no more work than our tip isn't taken")
+    assert.Empty(t, n.chainSync.queue, "Its blocks aren't downloaded")
+
+    assert.Nil(t, n.handleHeaders(p, gobEncode(headers{"", fork[3:]})))
+    if assert.NotNil(t, n.chainSync.bestHeader, "The headers we already had count towards the work") {
+            assert.Equal(t, 4, n.chainSync.bestHeader.height)
+    }
+    assert.Len(t, n.chainSync.queue, 4)
+
+    maxSyncHeaders = 1
+    side := forkHeaders(&genesis, 2, 100)
+    assert.Nil(t, n.handleHeaders(p, gobEncode(headers{"", side})))
+    last := forkHeaders(&genesis, 1, 200)
+    assert.Nil(t, n.handleHeaders(p, gobEncode(headers{"", last})))
+    assert.Len(t, n.chainSync.headers, 5, "Only the best header chain and the one being sent are kept")
+    assert.Nil(t, n.chainSync.headers[hex.EncodeToString(side[0].Hash())])
+    assert.NotNil(t, n.chainSync.headers[hex.EncodeToString(last[0].Hash())])
+}
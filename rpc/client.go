@@ -0,0 +1,240 @@
+package rpc
+
+import (
+    "bytes"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sync/atomic"
+    "time"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// Client calls the JSON-RPC server of a running node
+type Client struct {
+    url    string
+    http   *http.Client
+    nextID int64
+}
+
+// NewClient creates a client for the server listening on host:port
+func NewClient(addr string) *Client {
+    return &Client{url: "http://" + addr + "/", http: &http.Client{Timeout: 5 * time.Minute}} // sending on a mining node waits for the block
+}
+
+// Call calls method with params and decodes its result into result.
+// Errors the server answers with are returned as *Error.
+func (c *Client) Call(method string, result interface{}, params ...interface{}) error {
+    if params == nil {
+            params = []interface{}{}
+    }
+    id := atomic.AddInt64(&c.nextID, 1)
+    body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params, "id": id})
+    if err != nil {
+            return err
+    }
+
+    resp, err := c.http.Post(c.url, "application/json", bytes.NewReader(body))
+    if err != nil {
+            return err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+            return fmt.Errorf("%s: %s", method, resp.Status)
+    }
+
+    var r response
+    err = json.NewDecoder(resp.Body).Decode(&r)
+    if err != nil {
+            return err
+    }
+    if r.Error != nil {
+            return r.Error
+    }
+    if result == nil {
+            return nil
+    }
+
+    return json.Unmarshal(r.Result, result)
+}
+
+// GetBlockCount returns the height of the node's best block
+func (c *Client) GetBlockCount() (int, error) {
+    var height int
+    err := c.Call("getblockcount", &height)
+
+    return height, err
+}
+
+// GetBestBlockHash returns the hash of the node's best block
+func (c *Client) GetBestBlockHash() ([]byte, error) {
+    var hash string
+    err := c.Call("getbestblockhash", &hash)
+    if err != nil {
+            return nil, err
+    }
+
+    return hex.DecodeString(hash)
+}
+
+// GetBlockHash returns the hash of the main chain block at height
+func (c *Client) GetBlockHash(height int) ([]byte, error) {
+    var hash string
+    err := c.Call("getblockhash", &hash, height)
+    if err != nil {
+            return nil, err
+    }
+
+    return hex.DecodeString(hash)
+}
+
+// GetBlock returns a block, decoded, along with what the node says about it
+func (c *Client) GetBlock(hash []byte) (*block.Block, *BlockResult, error) {
+    var result BlockResult
+    err := c.Call("getblock", &result, hex.EncodeToString(hash))
+    if err != nil {
+            return nil, nil, err
+    }
+    data, err := hex.DecodeString(result.Hex)
+    if err != nil {
+            return nil, nil, err
+    }
+    b, err := block.DeserializeBlock(data)
+    if err != nil {
+            return nil, nil, err
+    }
+    if !bytes.Equal(b.Hash, hash) {
+            return nil, nil, fmt.Errorf("asked for block %x, got %x", hash, b.Hash)
+    }
+
+    return b, &result, nil
+}
+
+// GetBalance returns the coins the address can spend
+func (c *Client) GetBalance(address string) (int, error) {
+    var balance int
+    err := c.Call("getbalance", &balance, address)
+
+    return balance, err
+}
+
+// GetTransaction returns a transaction of the main chain or the mempool, decoded, along with what the node says about it
+func (c *Client) GetTransaction(id []byte) (*transaction.Transaction, *TransactionResult, error) {
+    var result TransactionResult
+    err := c.Call("gettransaction", &result, hex.EncodeToString(id))
+    if err != nil {
+            return nil, nil, err
+    }
+    data, err := hex.DecodeString(result.Hex)
+    if err != nil {
+            return nil, nil, err
+    }
+    tx, err := transaction.DeserializeTransaction(data)
+    if err != nil {
+            return nil, nil, err
+    }
+
+    return &tx, &result, nil
+}
+
+// GetAddressHistory returns the transactions of the address, the node needs the address index
+func (c *Client) GetAddressHistory(address string) ([]HistoryResult, error) {
+    var history []HistoryResult
+    err := c.Call("getaddresshistory", &history, address)
+
+    return history, err
+}
+
+// GetSupply returns the circulating and the maximum supply of coins
+func (c *Client) GetSupply() (*SupplyInfo, error) {
+    var info SupplyInfo
+    err := c.Call("getsupply", &info)
+    if err != nil {
+            return nil, err
+    }
+
+    return &info, nil
+}
+
+// ListAddresses returns the addresses of the node's wallet file
+func (c *Client) ListAddresses() ([]string, error) {
+    var addresses []string
+    err := c.Call("listaddresses", &addresses)
+
+    return addresses, err
+}
+
+// ListUnspent returns the unspent outputs of the address
+func (c *Client) ListUnspent(address string) ([]UnspentResult, error) {
+    var unspent []UnspentResult
+    err := c.Call("listunspent", &unspent, address)
+
+    return unspent, err
+}
+
+// SendRawTransaction hands a signed transaction over to the node, which relays it
+func (c *Client) SendRawTransaction(tx *transaction.Transaction) error {
+    return c.Call("sendrawtransaction", nil, hex.EncodeToString(tx.Serialize()))
+}
+
+// CreateWallet has the node add a key to its wallet file and returns its address
+func (c *Client) CreateWallet() (string, error) {
+    var address string
+    err := c.Call("createwallet", &address)
+
+    return address, err
+}
+
+// NewTransaction creates a transaction like utxo.NewUTXOTransaction does, with the outputs
+// it spends and the transactions that created them coming from the node. The key stays here.
+func (c *Client) NewTransaction(w *wallet.Wallet, to string, amount, fee int) (*transaction.Transaction, error) {
+    unspent, err := c.ListUnspent(string(w.GetAddress()))
+    if err != nil {
+            return nil, err
+    }
+    acc := 0
+    validOutputs := make(map[string][]int)
+    for _, u := range unspent {
+            if acc >= amount+fee {
+                    break
+            }
+            acc += u.Value
+            validOutputs[u.Txid] = append(validOutputs[u.Txid], u.Vout)
+    }
+
+    tx, err := utxo.BuildTransaction(w, to, amount, fee, acc, validOutputs)
+    if err != nil {
+            return nil, err
+    }
+    prevTXs := make(map[string]transaction.Transaction)
+    for txid := range validOutputs {
+            id, err := hex.DecodeString(txid)
+            if err != nil {
+                    return nil, err
+            }
+            prevTX, _, err := c.GetTransaction(id)
+            if err != nil {
+                    return nil, err
+            }
+            prevTXs[txid] = *prevTX
+    }
+    err = tx.Sign(w.PrivateKey, prevTXs)
+    if err != nil {
+            return nil, err
+    }
+    tx.ID = tx.Hash()
+
+    return tx, nil
+}
+
+// NewTransactionWithFeeRate creates a transaction paying feeRate for every started kB of its size
+func (c *Client) NewTransactionWithFeeRate(w *wallet.Wallet, to string, amount, feeRate int) (*transaction.Transaction, error) {
+    return utxo.WithFeeRate(feeRate, func(fee int) (*transaction.Transaction, error) {
+            return c.NewTransaction(w, to, amount, fee)
+    })
+}
@@ -0,0 +1,30 @@
+package rpc
+
+import "fmt"
+
+// Error codes of the JSON-RPC 2.0 spec, and the ones of the node's methods
+const (
+    CodeParseError     = -32700
+    CodeInvalidRequest = -32600
+    CodeMethodNotFound = -32601
+    CodeInvalidParams  = -32602
+    CodeInternalError  = -32603
+
+    CodeMiscError = -1  // the node can't answer, like without an index the method needs
+    CodeNotFound  = -5  // the block or transaction asked for doesn't exist
+    CodeRejected  = -26 // the transaction didn't make it into the mempool
+)
+
+// Error is the error object of a JSON-RPC response
+type Error struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func newError(code int, format string, a ...interface{}) *Error {
+    return &Error{code, fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) Error() string {
+    return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
@@ -0,0 +1,418 @@
+package rpc
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "os"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// BlockResult is a block as getblock returns it
+type BlockResult struct {
+    Hash          string              `json:"hash"`
+    Height        int                 `json:"height"`
+    Confirmations int                 `json:"confirmations"` // -1 for blocks off the main chain
+    Version       int32               `json:"version"`
+    PrevBlockHash string              `json:"previousblockhash,omitempty"`
+    MerkleRoot    string              `json:"merkleroot"`
+    Time          int64               `json:"time"`
+    Bits          string              `json:"bits"`
+    Nonce         int                 `json:"nonce"`
+    Size          int                 `json:"size"`
+    Hex           string              `json:"hex"` // the serialized block
+    Transactions  []TransactionResult `json:"tx"`
+}
+
+// TransactionResult is a transaction, the block fields are only set for gettransaction
+// and left out for transactions still in the mempool
+type TransactionResult struct {
+    ID            string         `json:"txid"`
+    Hex           string         `json:"hex"` // the serialized transaction
+    Size          int            `json:"size"`
+    Coinbase      bool           `json:"coinbase,omitempty"`
+    Vin           []InputResult  `json:"vin"`
+    Vout          []OutputResult `json:"vout"`
+    BlockHash     string         `json:"blockhash,omitempty"`
+    BlockHeight   *int           `json:"blockheight,omitempty"`
+    Confirmations int            `json:"confirmations,omitempty"`
+}
+
+type InputResult struct {
+    Txid      string `json:"txid"`
+    Vout      int    `json:"vout"`
+    Signature string `json:"signature"`
+    PubKey    string `json:"pubkey"` // the coinbase data for coinbase inputs
+}
+
+type OutputResult struct {
+    Value      int    `json:"value"`
+    PubKeyHash string `json:"pubkeyhash"`
+    Address    string `json:"address"`
+}
+
+// UnspentResult is an unspent output as listunspent returns it
+type UnspentResult struct {
+    Txid          string `json:"txid"`
+    Vout          int    `json:"vout"`
+    Value         int    `json:"value"`
+    Height        int    `json:"height"`
+    Confirmations int    `json:"confirmations"`
+    Coinbase      bool   `json:"coinbase"`
+}
+
+// MempoolInfo is the result of getmempoolinfo
+type MempoolInfo struct {
+    Size  int `json:"size"`  // number of transactions
+    Bytes int `json:"bytes"` // their serialized size
+}
+
+// HistoryResult is a transaction of an address as getaddresshistory returns it
+type HistoryResult struct {
+    Txid   string `json:"txid"`
+    Height int    `json:"height"`
+}
+
+// SupplyInfo is the result of getsupply
+type SupplyInfo struct {
+    Height      int `json:"height"`
+    Circulating int `json:"circulating"` // coins in the UTXO set at the tip
+    Max         int `json:"max"`         // coins there will be once the subsidy has run out
+    NextSubsidy int `json:"nextsubsidy"`
+}
+
+// PeerInfo is a peer as getpeerinfo returns it
+type PeerInfo struct {
+    Addr       string `json:"addr"` // the address the peer listens on
+    RemoteAddr string `json:"remoteaddr"`
+    Inbound    bool   `json:"inbound"`
+    Version    int    `json:"version"`
+    BestHeight int    `json:"bestheight"`
+}
+
+// NewBlockResult describes a block that has the given number of confirmations
+func NewBlockResult(b *block.Block, confirmations int) BlockResult {
+    data := b.Serialize()
+    result := BlockResult{
+            Hash:          hex.EncodeToString(b.Hash),
+            Height:        b.Height,
+            Confirmations: confirmations,
+            Version:       b.Version,
+            PrevBlockHash: hex.EncodeToString(b.PrevBlockHash),
+            MerkleRoot:    hex.EncodeToString(b.MerkleRoot),
+            Time:          b.Timestamp,
+            Bits:          fmt.Sprintf("%08x", b.Bits),
+            Nonce:         b.Nonce,
+            Size:          len(data),
+            Hex:           hex.EncodeToString(data),
+    }
+    for _, tx := range b.Transactions {
+            result.Transactions = append(result.Transactions, NewTransactionResult(tx))
+    }
+
+    return result
+}
+
+// NewTransactionResult describes a transaction, without the block fields
+func NewTransactionResult(tx *transaction.Transaction) TransactionResult {
+    data := tx.Serialize()
+    result := TransactionResult{
+            ID:       hex.EncodeToString(tx.ID),
+            Hex:      hex.EncodeToString(data),
+            Size:     len(data),
+            Coinbase: tx.IsCoinbase(),
+            Vin:      []InputResult{},
+            Vout:     []OutputResult{},
+    }
+    for _, in := range tx.Vin {
+            result.Vin = append(result.Vin, InputResult{hex.EncodeToString(in.Txid), in.Vout, hex.EncodeToString(in.Signature), hex.EncodeToString(in.PubKey)})
+    }
+    for _, out := range tx.Vout {
+            result.Vout = append(result.Vout, OutputResult{out.Value, hex.EncodeToString(out.PubKeyHash), string(wallet.PubKeyHashToAddress(out.PubKeyHash))})
+    }
+
+    return result
+}
+
+// NewConfirmedTransactionResult describes a transaction along with the main chain block confirming it
+func NewConfirmedTransactionResult(tx *transaction.Transaction, b *block.Block, bestHeight int) TransactionResult {
+    result := NewTransactionResult(tx)
+    result.BlockHash = hex.EncodeToString(b.Hash)
+    result.BlockHeight = &b.Height
+    result.Confirmations = bestHeight - b.Height + 1
+
+    return result
+}
+
+func (s *Server) getBlockCount(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    return s.bc.GetBestHeight(), nil
+}
+
+func (s *Server) getBestBlockHash(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    return hex.EncodeToString(s.bc.Tip()), nil
+}
+
+// getblock [hash]
+func (s *Server) getBlock(params []json.RawMessage) (interface{}, error) {
+    hash, err := hexParam(params, "hash")
+    if err != nil {
+            return nil, err
+    }
+    b, err := s.bc.GetBlock(hash)
+    if errors.Is(err, chain.ErrBlockNotFound) {
+            return nil, newError(CodeNotFound, "block %x is not found", hash)
+    }
+    if err != nil {
+            return nil, err
+    }
+
+    return NewBlockResult(&b, s.bc.Confirmations(&b)), nil
+}
+
+// getblockhash [height] returns the hash of the main chain block at height
+func (s *Server) getBlockHash(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 1)
+    if err != nil {
+            return nil, err
+    }
+    var height int
+    err = json.Unmarshal(params[0], &height)
+    if err != nil {
+            return nil, newError(CodeInvalidParams, "height must be a number")
+    }
+    b, err := s.bc.GetBlockByHeight(height)
+    if err != nil {
+            return nil, newError(CodeNotFound, "no main chain block at height %d", height)
+    }
+
+    return hex.EncodeToString(b.Hash), nil
+}
+
+// gettransaction [txid] looks in the main chain, then in the mempool
+func (s *Server) getTransaction(params []json.RawMessage) (interface{}, error) {
+    id, err := hexParam(params, "txid")
+    if err != nil {
+            return nil, err
+    }
+
+    tx, b, err := s.bc.GetTransaction(id)
+    if err == nil {
+            return NewConfirmedTransactionResult(tx, b, s.bc.GetBestHeight()), nil
+    }
+    if !errors.Is(err, chain.ErrTransactionNotFound) {
+            return nil, err
+    }
+    if tx, ok := s.node.Mempool().Get(id); ok {
+            return NewTransactionResult(tx), nil
+    }
+
+    return nil, newError(CodeNotFound, "transaction %x is not found", id)
+}
+
+// getbalance [address]
+func (s *Server) getBalance(params []json.RawMessage) (interface{}, error) {
+    pubKeyHash, err := addressParam(params)
+    if err != nil {
+            return nil, err
+    }
+
+    balance := 0
+    for _, out := range (utxo.UTXOSet{Blockchain: s.bc}).FindUTXO(pubKeyHash) {
+            balance += out.Value
+    }
+
+    return balance, nil
+}
+
+// listunspent [address]
+func (s *Server) listUnspent(params []json.RawMessage) (interface{}, error) {
+    pubKeyHash, err := addressParam(params)
+    if err != nil {
+            return nil, err
+    }
+
+    result := []UnspentResult{}
+    bestHeight := s.bc.GetBestHeight()
+    for _, u := range (utxo.UTXOSet{Blockchain: s.bc}).ListUnspent(pubKeyHash) {
+            result = append(result, UnspentResult{hex.EncodeToString(u.Txid), u.Vout, u.Value, u.Height, bestHeight - u.Height + 1, u.Coinbase})
+    }
+
+    return result, nil
+}
+
+// getaddresshistory [address] lists the transactions paying to or spending from the address, oldest first
+func (s *Server) getAddressHistory(params []json.RawMessage) (interface{}, error) {
+    pubKeyHash, err := addressParam(params)
+    if err != nil {
+            return nil, err
+    }
+
+    history, ok := (utxo.UTXOSet{Blockchain: s.bc}).AddressHistory(pubKeyHash)
+    if !ok {
+            return nil, newError(CodeMiscError, "the node has no address index, build it with reindexutxo -addrindex")
+    }
+    result := []HistoryResult{}
+    for _, tx := range history {
+            result = append(result, HistoryResult{hex.EncodeToString(tx.Txid), tx.Height})
+    }
+
+    return result, nil
+}
+
+func (s *Server) getSupply(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    height := s.bc.GetBestHeight()
+    circulating := (utxo.UTXOSet{Blockchain: s.bc}).TotalValue()
+
+    return SupplyInfo{height, circulating, transaction.MaxSupply(), transaction.GetBlockSubsidy(height + 1)}, nil
+}
+
+// sendrawtransaction [hex] puts a signed transaction in the mempool and relays it, returning its ID
+func (s *Server) sendRawTransaction(params []json.RawMessage) (interface{}, error) {
+    data, err := hexParam(params, "hex")
+    if err != nil {
+            return nil, err
+    }
+    tx, err := transaction.DeserializeTransaction(data)
+    if err != nil {
+            return nil, newError(CodeInvalidParams, "%s", err)
+    }
+
+    err = s.node.SubmitTx(&tx)
+    if err != nil {
+            return nil, newError(CodeRejected, "%s", err)
+    }
+
+    return hex.EncodeToString(tx.ID), nil
+}
+
+func (s *Server) getMempoolInfo(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+    mempool := s.node.Mempool()
+
+    return MempoolInfo{mempool.Count(), mempool.Size()}, nil
+}
+
+func (s *Server) getPeerInfo(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    result := []PeerInfo{}
+    for _, p := range s.node.Peers() {
+            result = append(result, PeerInfo{p.Addr(), p.RemoteAddr(), p.Inbound(), p.Version(), p.BestHeight()})
+    }
+
+    return result, nil
+}
+
+// createwallet adds a key to the node's wallet file and returns its address
+func (s *Server) createWallet(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    s.walletMu.Lock()
+    defer s.walletMu.Unlock()
+
+    wallets, err := wallet.NewWalletsFile(s.walletFile)
+    if err != nil && !os.IsNotExist(err) { // the first wallet creates the file
+            return nil, err
+    }
+    address := wallets.CreateWallet()
+    err = wallets.SaveToPath(s.walletFile)
+    if err != nil {
+            return nil, err
+    }
+
+    return address, nil
+}
+
+// listaddresses returns the addresses of the node's wallet file
+func (s *Server) listAddresses(params []json.RawMessage) (interface{}, error) {
+    err := checkParams(params, 0)
+    if err != nil {
+            return nil, err
+    }
+
+    s.walletMu.Lock()
+    defer s.walletMu.Unlock()
+
+    wallets, err := wallet.NewWalletsFile(s.walletFile)
+    if os.IsNotExist(err) {
+            return []string{}, nil
+    }
+    if err != nil {
+            return nil, err
+    }
+
+    return append([]string{}, wallets.GetAddresses()...), nil
+}
+
+// checkParams makes sure a method got the number of params it takes
+func checkParams(params []json.RawMessage, n int) error {
+    if len(params) != n {
+            return newError(CodeInvalidParams, "%d params expected, got %d", n, len(params))
+    }
+    return nil
+}
+
+// hexParam decodes the only param of a method, a hex string
+func hexParam(params []json.RawMessage, name string) ([]byte, error) {
+    err := checkParams(params, 1)
+    if err != nil {
+            return nil, err
+    }
+    var param string
+    err = json.Unmarshal(params[0], &param)
+    if err != nil {
+            return nil, newError(CodeInvalidParams, "%s must be a string", name)
+    }
+    data, err := hex.DecodeString(param)
+    if err != nil {
+            return nil, newError(CodeInvalidParams, "%s is not hex", name)
+    }
+
+    return data, nil
+}
+
+// addressParam returns the public key hash of the address that is the only param of a method
+func addressParam(params []json.RawMessage) ([]byte, error) {
+    err := checkParams(params, 1)
+    if err != nil {
+            return nil, err
+    }
+    var address string
+    err = json.Unmarshal(params[0], &address)
+    if err != nil || !wallet.ValidateAddress(address) {
+            return nil, newError(CodeInvalidParams, "address is not valid")
+    }
+    pubKeyHash := wallet.Base58Decode([]byte(address))
+
+    return pubKeyHash[1 : len(pubKeyHash)-4], nil
+}
@@ -0,0 +1,200 @@
+package rpc
+
+import (
+    "bytes"
+    "encoding/json"
+    "io/ioutil"
+    "net"
+    "net/http"
+    "sync"
+    "time"
+
+    "blockchain_go/chain"
+    "blockchain_go/p2p"
+)
+
+const maxRequestSize = 1 << 20 // bytes of a request body, batches included
+
+// Server answers JSON-RPC 2.0 requests POSTed over HTTP with what a running node knows.
+// Params are positional, batches and notifications are supported. There's no authentication,
+// so it should only listen where the node's owner can reach it.
+type Server struct {
+    bc         *chain.Blockchain
+    node       *p2p.Node
+    walletFile string
+    methods    map[string]handler
+
+    walletMu sync.Mutex // the wallet file is read, changed and written back as a whole
+
+    ln   net.Listener
+    http *http.Server
+}
+
+// handler runs a method with the positional params of the request
+type handler func(params []json.RawMessage) (interface{}, error)
+
+type request struct {
+    JSONRPC string          `json:"jsonrpc"`
+    Method  string          `json:"method"`
+    Params  json.RawMessage `json:"params"`
+    ID      json.RawMessage `json:"id"` // missing for notifications, which get no response
+}
+
+type response struct {
+    JSONRPC string          `json:"jsonrpc"`
+    Result  json.RawMessage `json:"result,omitempty"`
+    Error   *Error          `json:"error,omitempty"`
+    ID      json.RawMessage `json:"id"`
+}
+
+// NewServer creates a server for the node, which keeps its chain in bc and its wallets in walletFile
+func NewServer(bc *chain.Blockchain, node *p2p.Node, walletFile string) *Server {
+    s := &Server{bc: bc, node: node, walletFile: walletFile}
+    s.methods = map[string]handler{
+            "getblockcount":      s.getBlockCount,
+            "getbestblockhash":   s.getBestBlockHash,
+            "getblock":           s.getBlock,
+            "getblockhash":       s.getBlockHash,
+            "gettransaction":     s.getTransaction,
+            "getbalance":         s.getBalance,
+            "listunspent":        s.listUnspent,
+            "getaddresshistory":  s.getAddressHistory,
+            "getsupply":          s.getSupply,
+            "listaddresses":      s.listAddresses,
+            "sendrawtransaction": s.sendRawTransaction,
+            "getmempoolinfo":     s.getMempoolInfo,
+            "getpeerinfo":        s.getPeerInfo,
+            "createwallet":       s.createWallet,
+            "getnewaddress":      s.createWallet, // the wallet file holds one key per address
+    }
+    s.http = &http.Server{Handler: s, ReadTimeout: 30 * time.Second}
+
+    return s
+}
+
+// Listen opens the server's listening socket on host:port
+func (s *Server) Listen(addr string) error {
+    ln, err := net.Listen("tcp", addr)
+    if err != nil {
+            return err
+    }
+    s.ln = ln
+
+    return nil
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+    return s.ln.Addr().String()
+}
+
+// Serve answers requests until the server is closed
+func (s *Server) Serve() {
+    s.http.Serve(s.ln)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+    return s.http.Close()
+}
+
+// ServeHTTP answers a single request or a batch of them
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+            w.Header().Set("Allow", http.MethodPost)
+            http.Error(w, "JSON-RPC requests are POSTed", http.StatusMethodNotAllowed)
+            return
+    }
+    body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+    if err != nil {
+            http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+            return
+    }
+
+    var result interface{}
+    body = bytes.TrimSpace(body)
+    if len(body) > 0 && body[0] == '[' {
+            var batch []json.RawMessage
+            err = json.Unmarshal(body, &batch)
+            if err != nil {
+                    result = errorResponse(nil, newError(CodeParseError, "%s", err))
+            } else if len(batch) == 0 {
+                    result = errorResponse(nil, newError(CodeInvalidRequest, "empty batch"))
+            } else {
+                    var responses []*response
+                    for _, req := range batch {
+                            if resp := s.handle(req); resp != nil {
+                                    responses = append(responses, resp)
+                            }
+                    }
+                    if len(responses) > 0 {
+                            result = responses
+                    }
+            }
+    } else if resp := s.handle(body); resp != nil {
+            result = resp
+    }
+
+    if result == nil { // only notifications
+            w.WriteHeader(http.StatusNoContent)
+            return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(result)
+}
+
+// handle runs one request, it returns nil for notifications
+func (s *Server) handle(data []byte) *response {
+    var req request
+    err := json.Unmarshal(data, &req)
+    if err != nil {
+            if _, ok := err.(*json.SyntaxError); ok {
+                    return errorResponse(nil, newError(CodeParseError, "%s", err))
+            }
+            return errorResponse(nil, newError(CodeInvalidRequest, "%s", err))
+    }
+    if req.JSONRPC != "2.0" || req.Method == "" {
+            return errorResponse(req.ID, newError(CodeInvalidRequest, "not a JSON-RPC 2.0 request"))
+    }
+
+    result, err := s.call(req.Method, req.Params)
+    if req.ID == nil {
+            return nil
+    }
+    if err != nil {
+            return errorResponse(req.ID, err)
+    }
+    data, err = json.Marshal(result)
+    if err != nil {
+            return errorResponse(req.ID, err)
+    }
+
+    return &response{JSONRPC: "2.0", Result: data, ID: req.ID}
+}
+
+func (s *Server) call(method string, rawParams json.RawMessage) (interface{}, error) {
+    h := s.methods[method]
+    if h == nil {
+            return nil, newError(CodeMethodNotFound, "method %s not found", method)
+    }
+    var params []json.RawMessage
+    if len(rawParams) > 0 && string(rawParams) != "null" {
+            err := json.Unmarshal(rawParams, &params)
+            if err != nil {
+                    return nil, newError(CodeInvalidParams, "params must be an array")
+            }
+    }
+
+    return h(params)
+}
+
+// errorResponse turns err into the error object of a response, errors that
+// aren't an *Error are internal ones
+func errorResponse(id json.RawMessage, err error) *response {
+    rpcErr, ok := err.(*Error)
+    if !ok {
+            rpcErr = newError(CodeInternalError, "%s", err)
+    }
+
+    return &response{JSONRPC: "2.0", Error: rpcErr, ID: id}
+}
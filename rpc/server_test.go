@@ -0,0 +1,181 @@
+package rpc
+
+import (
+    "encoding/hex"
+    "encoding/json"
+    "io/ioutil"
+    "net/http"
+    "net/http/httptest"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/chain"
+    "blockchain_go/p2p"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// newTestServer serves a fresh chain paying its genesis reward to w, from a node that doesn't listen.
+// The chain and the wallet file of the node are kept in dir.
+func newTestServer(t *testing.T, dir string, w *wallet.Wallet) (*chain.Blockchain, *Client, func()) {
+    bc, err := chain.CreateBlockchainFile(filepath.Join(dir, "blockchain.db"), string(w.GetAddress()))
+    if err != nil {
+            t.Fatal(err)
+    }
+    n := p2p.NewNode(p2p.Config{}, bc)
+    ts := httptest.NewServer(NewServer(bc, n, filepath.Join(dir, "wallet.dat")))
+
+    return bc, NewClient(strings.TrimPrefix(ts.URL, "http://")), func() {
+            ts.Close()
+            n.Close()
+            bc.Close()
+    }
+}
+
+func TestServer(t *testing.T) {
+    w := wallet.NewWallet()
+    address := string(w.GetAddress())
+    dir := t.TempDir()
+    bc, c, stop := newTestServer(t, dir, w)
+    defer stop()
+
+    b, _, _, err := bc.MineBlock(nil, func(height int) *transaction.Transaction {
+            return transaction.NewCoinbaseTX(address, "", height, 0)
+    })
+    assert.Nil(t, err)
+
+    height, err := c.GetBlockCount()
+    assert.Nil(t, err)
+    assert.Equal(t, 1, height)
+    var best string
+    assert.Nil(t, c.Call("getbestblockhash", &best))
+    assert.Equal(t, hex.EncodeToString(b.Hash), best)
+
+    var blk BlockResult
+    assert.Nil(t, c.Call("getblock", &blk, best))
+    assert.Equal(t, 1, blk.Confirmations)
+    assert.Equal(t, hex.EncodeToString(b.PrevBlockHash), blk.PrevBlockHash)
+    assert.Equal(t, hex.EncodeToString(b.Transactions[0].ID), blk.Transactions[0].ID)
+    assert.Equal(t, address, blk.Transactions[0].Vout[0].Address)
+    hash, err := c.GetBlockHash(1)
+    assert.Nil(t, err)
+    assert.Equal(t, b.Hash, hash)
+    decoded, _, err := c.GetBlock(hash)
+    assert.Nil(t, err)
+    assert.Equal(t, b.Serialize(), decoded.Serialize(), "Blocks come whole, for printchain")
+    _, err = c.GetBlockHash(2)
+    assert.Equal(t, CodeNotFound, err.(*Error).Code)
+    supply, err := c.GetSupply()
+    assert.Nil(t, err)
+    assert.Equal(t, SupplyInfo{1, 2 * transaction.GetBlockSubsidy(0), transaction.MaxSupply(), transaction.GetBlockSubsidy(2)}, *supply)
+    _, err = c.GetAddressHistory(address)
+    assert.Equal(t, CodeMiscError, err.(*Error).Code, "History needs the address index")
+    assert.Nil(t, (utxo.UTXOSet{Blockchain: bc}).ReindexAddresses())
+    history, err := c.GetAddressHistory(address)
+    assert.Nil(t, err)
+    if assert.Len(t, history, 2, "The genesis and the block 1 coinbases") {
+            assert.Equal(t, HistoryResult{hex.EncodeToString(b.Transactions[0].ID), 1}, history[1])
+    }
+
+    balance, err := c.GetBalance(address)
+    assert.Nil(t, err)
+    assert.Equal(t, 2*transaction.GetBlockSubsidy(0), balance)
+    unspent, err := c.ListUnspent(address)
+    assert.Nil(t, err)
+    assert.Len(t, unspent, 2)
+
+    // the key stays with the client, the node only gets the signed transaction
+    to := string(wallet.NewWallet().GetAddress())
+    tx, err := c.NewTransaction(w, to, 3, 1)
+    assert.Nil(t, err)
+    assert.Nil(t, c.SendRawTransaction(tx))
+    assert.NotNil(t, c.SendRawTransaction(tx), "The mempool already has it")
+
+    var info MempoolInfo
+    assert.Nil(t, c.Call("getmempoolinfo", &info))
+    assert.Equal(t, MempoolInfo{1, tx.Size()}, info)
+    found, result, err := c.GetTransaction(tx.ID)
+    assert.Nil(t, err)
+    assert.Equal(t, tx, found)
+    assert.Nil(t, result.BlockHeight, "Mempool transactions aren't in a block yet")
+
+    _, result, err = c.GetTransaction(b.Transactions[0].ID)
+    assert.Nil(t, err)
+    assert.Equal(t, 1, *result.BlockHeight)
+    assert.Equal(t, 1, result.Confirmations)
+
+    var peers []PeerInfo
+    assert.Nil(t, c.Call("getpeerinfo", &peers))
+    assert.Empty(t, peers)
+
+    addresses, err := c.ListAddresses()
+    assert.Nil(t, err)
+    assert.Empty(t, addresses, "There's no wallet file yet")
+    newAddress, err := c.CreateWallet()
+    assert.Nil(t, err)
+    addresses, err = c.ListAddresses()
+    assert.Nil(t, err)
+    assert.Equal(t, []string{newAddress}, addresses)
+    wallets, err := wallet.NewWalletsFile(filepath.Join(dir, "wallet.dat"))
+    assert.Nil(t, err)
+    assert.Equal(t, []string{newAddress}, wallets.GetAddresses(), "The key is saved in the node's wallet file")
+}
+
+func TestServerErrors(t *testing.T) {
+    _, c, stop := newTestServer(t, t.TempDir(), wallet.NewWallet())
+    defer stop()
+
+    code := func(err error) int {
+            if rpcErr, ok := err.(*Error); ok {
+                    return rpcErr.Code
+            }
+            return 0
+    }
+    assert.Equal(t, CodeMethodNotFound, code(c.Call("stop", nil)))
+    assert.Equal(t, CodeInvalidParams, code(c.Call("getblockcount", nil, 1)))
+    assert.Equal(t, CodeInvalidParams, code(c.Call("getblock", nil, "zz")))
+    assert.Equal(t, CodeInvalidParams, code(c.Call("getbalance", nil, "not an address")))
+    assert.Equal(t, CodeNotFound, code(c.Call("getblock", nil, strings.Repeat("00", 32))))
+    assert.Equal(t, CodeNotFound, code(c.Call("gettransaction", nil, strings.Repeat("00", 32))))
+    assert.Equal(t, CodeInvalidParams, code(c.Call("sendrawtransaction", nil, "00")))
+
+    post := func(body string) (int, string) {
+            resp, err := http.Post(c.url, "application/json", strings.NewReader(body))
+            if !assert.Nil(t, err) {
+                    return 0, ""
+            }
+            defer resp.Body.Close()
+            data, _ := ioutil.ReadAll(resp.Body)
+            return resp.StatusCode, string(data)
+    }
+    var r response
+    _, body := post(`{"jsonrpc": "2.0", "method": "getblockcount", "id": 1`)
+    assert.Nil(t, json.Unmarshal([]byte(body), &r))
+    assert.Equal(t, CodeParseError, r.Error.Code)
+    assert.Equal(t, "null", string(r.ID), "The ID of an unreadable request is null")
+
+    _, body = post(`{"method": "getblockcount", "id": 1}`)
+    assert.Nil(t, json.Unmarshal([]byte(body), &r))
+    assert.Equal(t, CodeInvalidRequest, r.Error.Code, "The version is required")
+
+    status, _ := post(`{"jsonrpc": "2.0", "method": "getblockcount"}`)
+    assert.Equal(t, http.StatusNoContent, status, "Notifications get no response")
+
+    var batch []response
+    _, body = post(`[{"jsonrpc": "2.0", "method": "getblockcount", "id": "a"}, {"jsonrpc": "2.0", "method": "getblockcount"}, {"jsonrpc": "2.0", "method": "nope", "id": 2}]`)
+    assert.Nil(t, json.Unmarshal([]byte(body), &batch))
+    if assert.Len(t, batch, 2, "The notification in the batch gets no response") {
+            assert.Equal(t, `"a"`, string(batch[0].ID))
+            assert.Equal(t, "0", string(batch[0].Result))
+            assert.Equal(t, CodeMethodNotFound, batch[1].Error.Code)
+    }
+
+    resp, err := http.Get(c.url)
+    assert.Nil(t, err)
+    resp.Body.Close()
+    assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
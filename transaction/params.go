@@ -0,0 +1,42 @@
+package transaction
+
+import (
+    "fmt"
+    "math"
+)
+
+// Consensus parameters of the coin supply. Every node of a network has to use the same values.
+var (
+    // initialSubsidy is the number of coins a block creates before the first halving
+    initialSubsidy = 10
+
+    // halvingInterval is the number of blocks after which the subsidy is halved
+    halvingInterval = 1000
+)
+
+// Params are the emission schedule a network may choose
+type Params struct {
+    InitialSubsidy  int // coins a block creates before the first halving
+    HalvingInterval int // blocks after which the subsidy is halved
+}
+
+// GetParams returns the emission schedule in use
+func GetParams() Params {
+    return Params{initialSubsidy, halvingInterval}
+}
+
+// SetParams changes the emission schedule. It has to happen at startup, before any block is mined or validated.
+func SetParams(p Params) error {
+    if p.InitialSubsidy <= 0 || p.HalvingInterval <= 0 {
+            return fmt.Errorf("initial subsidy and halving interval must be positive, not %d and %d", p.InitialSubsidy, p.HalvingInterval)
+    }
+    // the subsidy runs out after 64 intervals and the max supply is below twice the
+    // coins of the first one, both have to fit in an int
+    if p.HalvingInterval > math.MaxInt64/64 || p.InitialSubsidy > math.MaxInt64/2/p.HalvingInterval {
+            return fmt.Errorf("a subsidy of %d every block for %d blocks is more coins than can be counted", p.InitialSubsidy, p.HalvingInterval)
+    }
+    initialSubsidy = p.InitialSubsidy
+    halvingInterval = p.HalvingInterval
+
+    return nil
+}
@@ -0,0 +1,170 @@
+package transaction
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+)
+
+// Transactions are serialized in a fixed binary layout, for hashing, storage and the
+// wire, so their IDs don't depend on the Go encoder and any client can compute them:
+//
+//    ID             varbytes, empty when the transaction is hashed
+//    input count    varint
+//    inputs         Txid varbytes | Vout int32 | Signature varbytes | PubKey varbytes
+//    output count   varint
+//    outputs        Value int64 | PubKeyHash varbytes
+//
+// A varint is an unsigned LEB128 number as encoding/binary writes it, varbytes are a
+// varint length followed by that many bytes and fixed-width integers are little-endian.
+// The ID of a transaction is the SHA-256 of its serialization with an empty ID.
+
+// minInputSize and minOutputSize are the fewest bytes an input and an output take,
+// they bound the counts a decoder accepts by what is left to read
+const minInputSize = 1 + 4 + 1 + 1
+const minOutputSize = 8 + 1
+
+var errTrailingData = errors.New("data left after the end")
+
+// Serialize returns the canonical serialization of the Transaction
+func (tx Transaction) Serialize() []byte {
+    var buff bytes.Buffer
+
+    writeVarBytes(&buff, tx.ID)
+    writeVarint(&buff, uint64(len(tx.Vin)))
+    for _, in := range tx.Vin {
+        writeVarBytes(&buff, in.Txid)
+        writeInt(&buff, int32(in.Vout))
+        writeVarBytes(&buff, in.Signature)
+        writeVarBytes(&buff, in.PubKey)
+    }
+    writeVarint(&buff, uint64(len(tx.Vout)))
+    for _, out := range tx.Vout {
+        writeInt(&buff, int64(out.Value))
+        writeVarBytes(&buff, out.PubKeyHash)
+    }
+
+    return buff.Bytes()
+}
+
+// DeserializeTransaction deserializes a transaction, failing on data that isn't exactly one
+func DeserializeTransaction(data []byte) (Transaction, error) {
+    r := bytes.NewReader(data)
+    tx, err := ReadTransaction(r)
+    if err == nil && r.Len() != 0 {
+        err = errTrailingData
+    }
+    if err != nil {
+        return Transaction{}, fmt.Errorf("can't decode transaction: %s", err)
+    }
+
+    return *tx, nil
+}
+
+// ReadTransaction reads a serialized transaction from r and leaves r right after it,
+// so transactions can be read one after the other
+func ReadTransaction(r *bytes.Reader) (*Transaction, error) {
+    var tx Transaction
+    var err error
+
+    tx.ID, err = readVarBytes(r)
+    if err != nil {
+        return nil, err
+    }
+
+    inputs, err := readCount(r, minInputSize)
+    if err != nil {
+        return nil, err
+    }
+    for i := 0; i < inputs; i++ {
+        var in TXInput
+        var vout int32
+        in.Txid, err = readVarBytes(r)
+        if err == nil {
+            err = binary.Read(r, binary.LittleEndian, &vout)
+        }
+        if err == nil {
+            in.Signature, err = readVarBytes(r)
+        }
+        if err == nil {
+            in.PubKey, err = readVarBytes(r)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("input %d: %s", i, err)
+        }
+        in.Vout = int(vout)
+        tx.Vin = append(tx.Vin, in)
+    }
+
+    outputs, err := readCount(r, minOutputSize)
+    if err != nil {
+        return nil, err
+    }
+    for i := 0; i < outputs; i++ {
+        var out TXOutput
+        var value int64
+        err = binary.Read(r, binary.LittleEndian, &value)
+        if err == nil {
+            out.PubKeyHash, err = readVarBytes(r)
+        }
+        if err != nil {
+            return nil, fmt.Errorf("output %d: %s", i, err)
+        }
+        out.Value = int(value)
+        tx.Vout = append(tx.Vout, out)
+    }
+
+    return &tx, nil
+}
+
+func writeVarint(buff *bytes.Buffer, n uint64) {
+    var varint [binary.MaxVarintLen64]byte
+    buff.Write(varint[:binary.PutUvarint(varint[:], n)])
+}
+
+func writeVarBytes(buff *bytes.Buffer, data []byte) {
+    writeVarint(buff, uint64(len(data)))
+    buff.Write(data)
+}
+
+// writeInt writes a fixed-width integer in little-endian order
+func writeInt(buff *bytes.Buffer, n interface{}) {
+    err := binary.Write(buff, binary.LittleEndian, n)
+    if err != nil {
+        log.Panic(err)
+    }
+}
+
+// readCount reads the number of items that follow, each taking at least minSize bytes
+func readCount(r *bytes.Reader, minSize int) (int, error) {
+    n, err := binary.ReadUvarint(r)
+    if err != nil {
+        return 0, err
+    }
+    if n > uint64(r.Len()/minSize) {
+        return 0, fmt.Errorf("count %d is more than the data holds", n)
+    }
+
+    return int(n), nil
+}
+
+// readVarBytes reads a length-prefixed byte string, empty ones come back nil
+func readVarBytes(r *bytes.Reader) ([]byte, error) {
+    n, err := binary.ReadUvarint(r)
+    if err != nil {
+        return nil, err
+    }
+    if n > uint64(r.Len()) {
+        return nil, io.ErrUnexpectedEOF
+    }
+    if n == 0 {
+        return nil, nil
+    }
+    data := make([]byte, n)
+    _, err = io.ReadFull(r, data)
+
+    return data, err
+}
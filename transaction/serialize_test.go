@@ -0,0 +1,43 @@
+package transaction
+
+import (
+    "bytes"
+    "encoding/hex"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestSerializeGolden(t *testing.T) {
+    tx := Transaction{
+        []byte{0xaa, 0xbb},
+        []TXInput{{[]byte{0x11, 0x11, 0x11}, 1, []byte{0x22, 0x22}, []byte{0x33}}},
+        []TXOutput{{300, []byte{0x44, 0x44}}},
+    }
+    expected := "02aabb" + "01" + "03111111" + "01000000" + "022222" + "0133" + "01" + "2c01000000000000" + "024444"
+    assert.Equal(t, expected, hex.EncodeToString(tx.Serialize()))
+    assert.Equal(t, "8f52b448216be49c8eeae0b60b03348d92903e909e5d7bda7758d54eb5dbcb5f", hex.EncodeToString(tx.Hash()), "The ID is hashed over an empty ID")
+
+    coinbase := Transaction{nil, []TXInput{{nil, -1, nil, []byte("hi")}}, []TXOutput{{10, []byte{0x55}}}}
+    expected = "00" + "01" + "00" + "ffffffff" + "00" + "026869" + "01" + "0a00000000000000" + "0155"
+    assert.Equal(t, expected, hex.EncodeToString(coinbase.Serialize()))
+}
+
+func TestSerializeRoundTrip(t *testing.T) {
+    // lengths past 127 take two varint bytes, empty fields come back nil
+    tx := &Transaction{nil, []TXInput{{[]byte{1}, 7, bytes.Repeat([]byte{2}, 200), []byte{3}}}, []TXOutput{{1 << 40, []byte{4}}, {0, nil}}}
+    tx.ID = tx.Hash()
+
+    decoded, err := DeserializeTransaction(tx.Serialize())
+    assert.Nil(t, err)
+    assert.Equal(t, *tx, decoded)
+    assert.Equal(t, tx.ID, decoded.Hash())
+
+    data := tx.Serialize()
+    _, err = DeserializeTransaction(append(data, 0))
+    assert.NotNil(t, err, "Trailing data is rejected")
+    _, err = DeserializeTransaction(data[:len(data)-1])
+    assert.NotNil(t, err, "Truncated data is rejected")
+    _, err = DeserializeTransaction([]byte{0, 0xff, 0xff, 0xff, 0xff, 0x0f})
+    assert.NotNil(t, err, "Counts larger than the data are rejected before allocating")
+}
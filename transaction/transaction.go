@@ -1,4 +1,4 @@
-package main
+package transaction
 
 import (
     "fmt"
@@ -8,13 +8,17 @@ import (
     "crypto/ecdsa"
     "strings"
     "crypto/rand"
-    "bytes"
     "math/big"
-    "encoding/gob"
     "crypto/elliptic"
+    "errors"
 )
 
-const subsidy = 10
+// Errors returned when building, signing and verifying transactions
+var (
+    ErrNotEnoughFunds   = errors.New("not enough funds")
+    ErrMissingPrevTx    = errors.New("previous transaction is not found")
+    ErrInvalidSignature = errors.New("invalid signature")
+)
 
 type Transaction struct {
     ID   []byte  // 该笔交易的交易ID
@@ -27,19 +31,6 @@ func (tx Transaction) IsCoinbase() bool {
     return len(tx.Vin) == 1 && len(tx.Vin[0].Txid) == 0 && tx.Vin[0].Vout == -1
 }
 
-// Serialize returns a serialized Transaction
-func (tx Transaction) Serialize() []byte {
-    var encoded bytes.Buffer
-
-    enc := gob.NewEncoder(&encoded)
-    err := enc.Encode(tx)
-    if err != nil {
-            log.Panic(err)
-    }
-        
-    return encoded.Bytes()
-}
-
 // Hash returns the hash of the Transaction
 func (tx *Transaction) Hash() []byte {
     var hash [32]byte
@@ -52,17 +43,18 @@ func (tx *Transaction) Hash() []byte {
     return hash[:]
 }
 
-
-
-// Sign signs each input of a Transaction
-func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction){ // look at signing-scheme.png
+// Sign signs each input of a Transaction.
+// A signature is r and s padded to 32 bytes each, 64 bytes in all. Older versions stored
+// r and s without padding, so Verify, which splits the signature in half, rejected their
+// signatures whenever r and s had different lengths.
+// It fails with ErrMissingPrevTx if an input's output isn't among prevTXs.
+func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transaction) error { // look at signing-scheme.png
     if tx.IsCoinbase() {           // in order to sign a transaction, we need to access the outputs referenced in the inputs of the transaction, thus we need the transactions that store these outputs.
-            return
+            return nil
     }
-    for _, vin := range tx.Vin {  // check Previous transaction is not correct 
-            if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-                    log.Panic("ERROR: Previous transaction is not correct")
-            }
+    err := checkPrevTXs(tx, prevTXs)
+    if err != nil {
+            return err
     }
     txCopy := tx.TrimmedCopy()
     for inID, vin := range txCopy.Vin {  // inputs are signed separately
@@ -74,11 +66,25 @@ func (tx *Transaction) Sign(privKey ecdsa.PrivateKey, prevTXs map[string]Transac
                         
             r, s, err := ecdsa.Sign(rand.Reader, &privKey, txCopy.ID)// the central piece, privKey and the data we're going to sign
             if err != nil {
-                    log.Panic(err)
+                    return err
             }
-            signature := append(r.Bytes(), s.Bytes()...)
+            signature := make([]byte, 64) // r and s take 32 bytes each, so Verify can split them
+            r.FillBytes(signature[:32])
+            s.FillBytes(signature[32:])
             tx.Vin[inID].Signature = signature
     }
+    return nil
+}
+
+// checkPrevTXs makes sure the output spent by every input is among prevTXs
+func checkPrevTXs(tx *Transaction, prevTXs map[string]Transaction) error {
+    for _, vin := range tx.Vin {
+            prevTx := prevTXs[hex.EncodeToString(vin.Txid)]
+            if prevTx.ID == nil || vin.Vout < 0 || vin.Vout >= len(prevTx.Vout) {
+                    return fmt.Errorf("%w: %x:%d", ErrMissingPrevTx, vin.Txid, vin.Vout)
+            }
+    }
+    return nil
 }
 
 // String returns a human-readable representation of a transaction
@@ -121,16 +127,15 @@ func (tx *Transaction) TrimmedCopy() Transaction {
     return txCopy
 }
 
-// Verify verifies signatures of Transaction inputs
-func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
+// Verify verifies signatures of Transaction inputs.
+// It returns ErrInvalidSignature if one doesn't match, and ErrMissingPrevTx if an input's output isn't among prevTXs.
+func (tx *Transaction) Verify(prevTXs map[string]Transaction) error {
     if tx.IsCoinbase() {
-            return true
+            return nil
     }
-        
-    for _, vin := range tx.Vin {
-            if prevTXs[hex.EncodeToString(vin.Txid)].ID == nil {
-                    log.Panic("ERROR: Previous transaction is not correct")
-            }
+    err := checkPrevTXs(tx, prevTXs)
+    if err != nil {
+            return err
     }
     txCopy := tx.TrimmedCopy()  // A trimmed copy will be signed, not a full transaction
     curve := elliptic.P256()    // used to generate key pairs
@@ -153,17 +158,18 @@ func (tx *Transaction) Verify(prevTXs map[string]Transaction) bool {
             x.SetBytes(vin.PubKey[:(keyLen / 2)])
             y.SetBytes(vin.PubKey[(keyLen / 2):])
 
-            rawPubKey := ecdsa.PublicKey{curve, &x, &y} // private key sign, public key verify
+            rawPubKey := ecdsa.PublicKey{Curve: curve, X: &x, Y: &y} // private key sign, public key verify
             if ecdsa.Verify(&rawPubKey, txCopy.ID, &r, &s) == false {
-                return false
+                return fmt.Errorf("%w in input %d", ErrInvalidSignature, inID)
             }
     }
-    return true
+    return nil
 }
 
 
 // A coinbase transaction has only one input.
-func NewCoinbaseTX(to, data string) *Transaction {
+// It pays the subsidy of the block at height plus the fees of the other transactions in the block.
+func NewCoinbaseTX(to, data string, height, fees int) *Transaction {
     if data == "" {
         randData := make([]byte, 20)
         _, err := rand.Read(randData)
@@ -175,58 +181,34 @@ func NewCoinbaseTX(to, data string) *Transaction {
     }
         
     txin := TXInput{[]byte{}, -1, nil, []byte(data)}
-    txout := NewTXOutput(subsidy, to)
+    txout := NewTXOutput(GetBlockSubsidy(height)+fees, to)
     tx := Transaction{nil, []TXInput{txin}, []TXOutput{*txout}}
     tx.ID = tx.Hash() 
     return &tx
 }
 
-// NewUTXOTransaction creates a new transaction
-func NewUTXOTransaction(wallet *Wallet, to string, amount int, UTXOSet *UTXOSet) *Transaction {
-    var inputs []TXInput
-    var outputs []TXOutput
+// GetBlockSubsidy returns the number of new coins the block at height may create.
+// It starts at initialSubsidy and is halved every halvingInterval blocks until it reaches zero.
+func GetBlockSubsidy(height int) int {
+    halvings := uint(height / halvingInterval)
+    if halvings >= 63 {
+            return 0
+    }
 
-    pubKeyHash := HashPubKey(wallet.PublicKey)
-    acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount)
+    return initialSubsidy >> halvings
+}
 
-    if acc < amount {
-            log.Panic("ERROR: Not enough funds")
-    }
-        
-    // Build a list of input. 从能使用的output中构建input，比如tx0.Output 1，tx1.Output 0，tx3.Output 0等等
-    for txid, outs := range validOutputs {  // range循环用在map时，txid as key， outs as value
-            txID, err := hex.DecodeString(txid)
-            if err != nil {
-                    log.Panic(err)
-            }
-                                    
-            for _, out := range outs {
-                    input := TXInput{txID, out, nil, wallet.PublicKey}
-                    inputs = append(inputs, input)
-            }
-    }
-    // Build a list of outputs.                           create two outputs
-    from := fmt.Sprintf("%s", wallet.GetAddress())
-    outputs = append(outputs, *NewTXOutput(amount, to)) // locked by receiver address 
-    if acc > amount {
-            outputs = append(outputs, *NewTXOutput(acc - amount, from)) // a change,  locked by sender address
+// MaxSupply returns the number of coins that will exist once the subsidy has run out
+func MaxSupply() int {
+    supply := 0
+    for height := 0; GetBlockSubsidy(height) > 0; height += halvingInterval {
+            supply += GetBlockSubsidy(height) * halvingInterval
     }
 
-    tx := Transaction{nil, inputs, outputs}
-    tx.ID = tx.Hash()
-    UTXOSet.Blockchain.SignTransaction(&tx, wallet.PrivateKey)
-    return &tx
+    return supply
 }
 
-// DeserializeTransaction deserializes a transaction
-func DeserializeTransaction(data []byte) Transaction {
-    var transaction Transaction
-
-    decoder := gob.NewDecoder(bytes.NewReader(data))
-    err := decoder.Decode(&transaction)
-    if err != nil {
-            log.Panic(err)
-    }
-        
-    return transaction
+// Size returns the number of bytes of the serialized transaction
+func (tx Transaction) Size() int {
+    return len(tx.Serialize())
 }
@@ -1,6 +1,10 @@
-package main
+package transaction
 
-import "bytes"
+import (
+    "bytes"
+
+    "blockchain_go/wallet"
+)
 
 
 // TXInput represents a transaction input
@@ -14,7 +18,7 @@ type TXInput struct {
 // checks that an input uses a specific key to unlock an output
 // UseKey checks whether the address initiated the transaction
 func (in *TXInput) UsesKey(pubKeyHash []byte) bool {
-    lockingHash := HashPubKey(in.PubKey)
+    lockingHash := wallet.HashPubKey(in.PubKey)
 
     return bytes.Compare(lockingHash, pubKeyHash) == 0
 }
@@ -1,9 +1,9 @@
-package main
+package transaction
 
 import (
         "bytes"
-        "log"
-        "encoding/gob"
+
+        "blockchain_go/wallet"
 )
 
 type TXOutput struct {
@@ -13,7 +13,7 @@ type TXOutput struct {
 
 // Lock simply locks an output. When we send coins to someone, we know only their address, thus the function takes an address as the only argument.
 func (out *TXOutput) Lock(address []byte) {
-    pubKeyHash := Base58Decode(address)
+    pubKeyHash := wallet.Base58Decode(address)
     pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-4]
     out.PubKeyHash = pubKeyHash
 }
@@ -29,35 +29,3 @@ func NewTXOutput(value int, address string) *TXOutput {
 
     return txo
 }
-
-// TXOutputs collects TXOutput
-type TXOutputs struct {
-    Outputs []TXOutput
-}
-
-// Serialize serializes TXOutputs
-func (outs TXOutputs) Serialize() []byte {
-    var buff bytes.Buffer
-
-    enc := gob.NewEncoder(&buff)
-    err := enc.Encode(outs)
-    if err != nil {
-            log.Panic(err)
-    }
-        
-    return buff.Bytes()
-}
-
-// DeserializeOutputs deserializes TXOutputs
-func DeserializeOutputs(data []byte) TXOutputs {
-    var outputs TXOutputs
-
-    dec := gob.NewDecoder(bytes.NewReader(data))
-    err := dec.Decode(&outputs)
-    if err != nil {
-            log.Panic(err)
-    }
-        
-    return outputs
-}
-
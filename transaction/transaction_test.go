@@ -0,0 +1,68 @@
+package transaction
+
+import (
+    "encoding/hex"
+    "errors"
+    "math"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/wallet"
+)
+
+func TestGetBlockSubsidy(t *testing.T) {
+    assert.Equal(t, initialSubsidy, GetBlockSubsidy(0), "Genesis gets the initial subsidy")
+    assert.Equal(t, initialSubsidy, GetBlockSubsidy(halvingInterval-1), "Subsidy is constant within an interval")
+    assert.Equal(t, initialSubsidy/2, GetBlockSubsidy(halvingInterval), "Subsidy halves after an interval")
+    assert.Equal(t, initialSubsidy/4, GetBlockSubsidy(2*halvingInterval), "Subsidy halves again")
+    assert.Equal(t, 0, GetBlockSubsidy(64*halvingInterval), "Subsidy runs out")
+}
+
+func TestMaxSupply(t *testing.T) {
+    supply := 0
+    for height := 0; height < 64*halvingInterval; height++ {
+            supply += GetBlockSubsidy(height)
+    }
+
+    assert.Equal(t, supply, MaxSupply(), "Max supply is the sum of all subsidies")
+}
+
+func TestEmissionParams(t *testing.T) {
+    defer SetParams(GetParams())
+
+    assert.NotNil(t, SetParams(Params{0, 100}))
+    assert.NotNil(t, SetParams(Params{50, 0}))
+    assert.NotNil(t, SetParams(Params{math.MaxInt64 / 100, 100}), "The max supply has to fit in an int")
+    assert.Equal(t, Params{10, 1000}, GetParams(), "Bad parameters change nothing")
+
+    assert.Nil(t, SetParams(Params{50, 210}))
+    assert.Equal(t, 50, GetBlockSubsidy(0))
+    assert.Equal(t, 50, GetBlockSubsidy(209), "The last block before the halving gets the full subsidy")
+    assert.Equal(t, 25, GetBlockSubsidy(210), "The subsidy halves at the halving interval")
+    assert.Equal(t, 25, GetBlockSubsidy(419))
+    assert.Equal(t, 12, GetBlockSubsidy(420), "Halving rounds down")
+    assert.Equal(t, 1, GetBlockSubsidy(5*210))
+    assert.Equal(t, 0, GetBlockSubsidy(6*210), "The subsidy runs out")
+    assert.Equal(t, 210*(50+25+12+6+3+1), MaxSupply())
+}
+
+func TestSignVerify(t *testing.T) {
+    w := wallet.NewWallet()
+    prev := NewCoinbaseTX(string(w.GetAddress()), "", 1, 0)
+    prevTXs := map[string]Transaction{hex.EncodeToString(prev.ID): *prev}
+
+    tx := Transaction{nil, []TXInput{{prev.ID, 0, nil, w.PublicKey}}, []TXOutput{*NewTXOutput(5, string(w.GetAddress()))}}
+    assert.Nil(t, tx.Sign(w.PrivateKey, prevTXs))
+    assert.Len(t, tx.Vin[0].Signature, 64, "r and s are padded to 32 bytes")
+    assert.Nil(t, tx.Verify(prevTXs))
+
+    tx.Vout[0].Value = 6
+    assert.True(t, errors.Is(tx.Verify(prevTXs), ErrInvalidSignature), "Changed outputs invalidate the signature")
+    tx.Vin[0].Signature = tx.Vin[0].Signature[:10]
+    assert.True(t, errors.Is(tx.Verify(prevTXs), ErrInvalidSignature), "Malformed signatures are rejected, not a panic")
+
+    tx.Vin[0].Vout = 1
+    assert.True(t, errors.Is(tx.Verify(prevTXs), ErrMissingPrevTx), "Outputs past the end of the previous transaction are missing")
+    assert.True(t, errors.Is(tx.Sign(w.PrivateKey, map[string]Transaction{}), ErrMissingPrevTx))
+}
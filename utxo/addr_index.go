@@ -0,0 +1,186 @@
+package utxo
+
+import (
+    "bytes"
+    "encoding/binary"
+    "log"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// The address index is optional: it is kept up to date only in databases where its
+// buckets exist, which reindexutxo -addrindex creates.
+const addrUTXOBucket = "addrutxo"        // pubkey hash + outpoint -> nothing, the address's unspent outputs
+const addrHistoryBucket = "addrhistory"  // pubkey hash + height + txid -> nothing, the transactions paying or spending from the address
+
+// AddressTx is a transaction in the history of an address
+type AddressTx struct {
+    Txid   []byte
+    Height int
+}
+
+// addrIndexEnabled tells whether the database has an address index
+func addrIndexEnabled(dbTx *bolt.Tx) bool {
+    return dbTx.Bucket([]byte(addrUTXOBucket)) != nil
+}
+
+// addrHistoryKey orders the history of an address by height
+func addrHistoryKey(pubKeyHash []byte, height int, txid []byte) []byte {
+    key := make([]byte, len(pubKeyHash)+4+len(txid))
+    copy(key, pubKeyHash)
+    binary.BigEndian.PutUint32(key[len(pubKeyHash):], uint32(height))
+    copy(key[len(pubKeyHash)+4:], txid)
+
+    return key
+}
+
+// addrPut and addrDelete add and remove an address index key, when the index is enabled
+func addrPut(dbTx *bolt.Tx, bucket string, key ...[]byte) {
+    if !addrIndexEnabled(dbTx) {
+            return
+    }
+    err := dbTx.Bucket([]byte(bucket)).Put(bytes.Join(key, nil), []byte{})
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+func addrDelete(dbTx *bolt.Tx, bucket string, key ...[]byte) {
+    if !addrIndexEnabled(dbTx) {
+            return
+    }
+    err := dbTx.Bucket([]byte(bucket)).Delete(bytes.Join(key, nil))
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// addrKeys calls f with what follows pubKeyHash in the keys of the bucket starting with it
+func addrKeys(dbTx *bolt.Tx, bucket string, pubKeyHash []byte, length int, f func(rest []byte)) {
+    c := dbTx.Bucket([]byte(bucket)).Cursor()
+    for k, _ := c.Seek(pubKeyHash); k != nil && bytes.HasPrefix(k, pubKeyHash); k, _ = c.Next() {
+            if len(k) == len(pubKeyHash)+length { // not a longer pubkey hash starting with the same bytes
+                    f(k[len(pubKeyHash):])
+            }
+    }
+}
+
+// ReindexAddresses builds the address index from the UTXO set and the main chain, creating it if needed
+func (u UTXOSet) ReindexAddresses() error {
+    var historyKeys [][]byte
+    err := u.Blockchain.RangeBlocks(0, u.Blockchain.GetBestHeight(), func(b *block.Block) bool {
+            for _, t := range b.Transactions {
+                    for _, pubKeyHash := range txAddresses(t) {
+                            historyKeys = append(historyKeys, addrHistoryKey(pubKeyHash, b.Height, t.ID))
+                    }
+            }
+            return true
+    })
+    if err != nil {
+            return err
+    }
+
+    return u.Blockchain.DB().Update(func(tx *bolt.Tx) error {
+            for _, name := range []string{addrUTXOBucket, addrHistoryBucket} {
+                    err := tx.DeleteBucket([]byte(name))
+                    if err != nil && err != bolt.ErrBucketNotFound {
+                            return err
+                    }
+                    _, err = tx.CreateBucket([]byte(name))
+                    if err != nil {
+                            return err
+                    }
+            }
+
+            err := tx.Bucket([]byte(utxoBucket)).ForEach(func(k, v []byte) error {
+                    addrPut(tx, addrUTXOBucket, DeserializeUTXOEntry(v).PubKeyHash, k)
+                    return nil
+            })
+            if err != nil {
+                    return err
+            }
+
+            for _, key := range historyKeys {
+                    addrPut(tx, addrHistoryBucket, key)
+            }
+
+            return nil
+    })
+}
+
+// indexHistory adds the transaction to the history of the addresses it pays to and spends from
+func indexHistory(dbTx *bolt.Tx, height int, tx *transaction.Transaction) {
+    for _, pubKeyHash := range txAddresses(tx) {
+            addrPut(dbTx, addrHistoryBucket, addrHistoryKey(pubKeyHash, height, tx.ID))
+    }
+}
+
+// unindexHistory reverts indexHistory
+func unindexHistory(dbTx *bolt.Tx, height int, tx *transaction.Transaction) {
+    for _, pubKeyHash := range txAddresses(tx) {
+            addrDelete(dbTx, addrHistoryBucket, addrHistoryKey(pubKeyHash, height, tx.ID))
+    }
+}
+
+// txAddresses returns the pubkey hashes a transaction pays to or spends from
+func txAddresses(tx *transaction.Transaction) [][]byte {
+    var pubKeyHashes [][]byte
+    for _, out := range tx.Vout {
+            pubKeyHashes = append(pubKeyHashes, out.PubKeyHash)
+    }
+    if !tx.IsCoinbase() {
+            for _, vin := range tx.Vin {
+                    pubKeyHashes = append(pubKeyHashes, wallet.HashPubKey(vin.PubKey)) // the key unlocking the output it spends
+            }
+    }
+
+    return pubKeyHashes
+}
+
+// AddressHistory returns the main chain transactions paying to or spending from the address, oldest first.
+// It returns false when the address index isn't enabled.
+func (u UTXOSet) AddressHistory(pubKeyHash []byte) ([]AddressTx, bool) {
+    var history []AddressTx
+    enabled := false
+
+    err := u.Blockchain.DB().View(func(tx *bolt.Tx) error {
+            enabled = addrIndexEnabled(tx)
+            if !enabled {
+                    return nil
+            }
+            addrKeys(tx, addrHistoryBucket, pubKeyHash, 4+32, func(rest []byte) {
+                    height := int(binary.BigEndian.Uint32(rest))
+                    history = append(history, AddressTx{append([]byte{}, rest[4:]...), height})
+            })
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return history, enabled
+}
+
+// addressOutputs calls f with the outpoints and entries of the unspent outputs locked to pubKeyHash,
+// looked up in the address index. It returns false when the index isn't enabled.
+func addressOutputs(dbTx *bolt.Tx, pubKeyHash []byte, f func(key []byte, entry UTXOEntry) bool) bool {
+    if !addrIndexEnabled(dbTx) {
+            return false
+    }
+    b := dbTx.Bucket([]byte(utxoBucket))
+    var keys [][]byte
+    addrKeys(dbTx, addrUTXOBucket, pubKeyHash, outpointLength, func(key []byte) {
+            keys = append(keys, key)
+    })
+    for _, key := range keys {
+            if !f(key, DeserializeUTXOEntry(b.Get(key))) {
+                    break
+            }
+    }
+
+    return true
+}
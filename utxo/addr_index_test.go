@@ -0,0 +1,53 @@
+package utxo_test
+
+import (
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+func TestAddressIndex(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    receiver := wallet.NewWallet()
+    bc := createBlockchain(t, string(miner.GetAddress()), dbFile)
+    defer bc.Close()
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+
+    first := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 1, 0)})
+    history, ok := UTXOSet.AddressHistory(wallet.HashPubKey(miner.PublicKey))
+    assert.False(t, ok, "The index is optional")
+    assert.Empty(t, history)
+    assert.Nil(t, UTXOSet.ReindexAddresses())
+
+    spend := spendCoinbase(bc, miner, first, string(receiver.GetAddress()), 5, 1)
+    block := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 2, 1), spend})
+
+    history, ok = UTXOSet.AddressHistory(wallet.HashPubKey(receiver.PublicKey))
+    assert.True(t, ok)
+    assert.Equal(t, []utxo.AddressTx{{spend.ID, 2}}, history)
+    history, _ = UTXOSet.AddressHistory(wallet.HashPubKey(miner.PublicKey))
+    assert.Equal(t, 4, len(history), "Coinbases and spends are in the history")
+    assert.Equal(t, []int{0, 1, 2, 2}, []int{history[0].Height, history[1].Height, history[2].Height, history[3].Height})
+
+    assert.Equal(t, []transaction.TXOutput{spend.Vout[0]}, UTXOSet.FindUTXO(wallet.HashPubKey(receiver.PublicKey)))
+    amount, outputs := UTXOSet.FindSpendableOutputs(wallet.HashPubKey(miner.PublicKey), 1000)
+    assert.Equal(t, UTXOSet.TotalValue()-5, amount, "All the other outputs are the miner's")
+    assert.Equal(t, 3, len(outputs))
+
+    UTXOSet.Reindex()
+    assert.Equal(t, []transaction.TXOutput{spend.Vout[0]}, UTXOSet.FindUTXO(wallet.HashPubKey(receiver.PublicKey)), "Reindexing keeps the address index")
+
+    UTXOSet.Disconnect(block)
+    history, _ = UTXOSet.AddressHistory(wallet.HashPubKey(receiver.PublicKey))
+    assert.Empty(t, history, "Disconnected blocks leave the history")
+    assert.Empty(t, UTXOSet.FindUTXO(wallet.HashPubKey(receiver.PublicKey)))
+    amount, _ = UTXOSet.FindSpendableOutputs(wallet.HashPubKey(miner.PublicKey), 1000)
+    assert.Equal(t, UTXOSet.TotalValue(), amount, "Spent outputs are back in the index")
+}
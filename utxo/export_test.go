@@ -0,0 +1,5 @@
+package utxo
+
+// the bucket names, for the tests looking into the DB
+const UTXOBucket = utxoBucket
+const UndoBucket = undoBucket
@@ -0,0 +1,85 @@
+package utxo
+
+import (
+    "encoding/hex"
+    "fmt"
+
+    "blockchain_go/transaction"
+    "blockchain_go/wallet"
+)
+
+// NewUTXOTransaction creates a new transaction.
+// Whatever the inputs hold beyond amount and fee comes back to the wallet as change,
+// the fee is left unclaimed for the miner. It fails with transaction.ErrNotEnoughFunds if the wallet can't pay for it.
+func NewUTXOTransaction(w *wallet.Wallet, to string, amount, fee int, UTXOSet *UTXOSet) (*transaction.Transaction, error) {
+    pubKeyHash := wallet.HashPubKey(w.PublicKey)
+    acc, validOutputs := UTXOSet.FindSpendableOutputs(pubKeyHash, amount+fee)
+
+    tx, err := BuildTransaction(w, to, amount, fee, acc, validOutputs)
+    if err != nil {
+            return nil, err
+    }
+    err = UTXOSet.Blockchain.SignTransaction(tx, w.PrivateKey)
+    if err != nil {
+            return nil, err
+    }
+    tx.ID = tx.Hash() // the ID covers the signatures, so it's only known once they're in
+    return tx, nil
+}
+
+// BuildTransaction creates the unsigned transaction spending validOutputs, worth acc, that pays
+// amount to to and fee to the miner, with the rest as change to the wallet
+func BuildTransaction(w *wallet.Wallet, to string, amount, fee, acc int, validOutputs map[string][]int) (*transaction.Transaction, error) {
+    var inputs []transaction.TXInput
+    var outputs []transaction.TXOutput
+
+    if acc < amount+fee {
+            return nil, fmt.Errorf("%w: %d needed, %d available", transaction.ErrNotEnoughFunds, amount+fee, acc)
+    }
+        
+    // Build a list of input. 从能使用的output中构建input，比如tx0.Output 1，tx1.Output 0，tx3.Output 0等等
+    for txid, outs := range validOutputs {  // range循环用在map时，txid as key， outs as value
+            txID, err := hex.DecodeString(txid)
+            if err != nil {
+                    return nil, err
+            }
+                                    
+            for _, out := range outs {
+                    input := transaction.TXInput{Txid: txID, Vout: out, PubKey: w.PublicKey}
+                    inputs = append(inputs, input)
+            }
+    }
+    // Build a list of outputs.                           create two outputs
+    from := fmt.Sprintf("%s", w.GetAddress())
+    outputs = append(outputs, *transaction.NewTXOutput(amount, to)) // locked by receiver address 
+    if acc > amount+fee {
+            outputs = append(outputs, *transaction.NewTXOutput(acc - amount - fee, from)) // a change,  locked by sender address
+    }
+
+    return &transaction.Transaction{Vin: inputs, Vout: outputs}, nil
+}
+
+// NewUTXOTransactionWithFeeRate creates a transaction paying feeRate for every started kB of its size
+func NewUTXOTransactionWithFeeRate(w *wallet.Wallet, to string, amount, feeRate int, UTXOSet *UTXOSet) (*transaction.Transaction, error) {
+    return WithFeeRate(feeRate, func(fee int) (*transaction.Transaction, error) {
+            return NewUTXOTransaction(w, to, amount, fee, UTXOSet)
+    })
+}
+
+// WithFeeRate calls create with growing fees until the transaction it returns pays feeRate
+// for every started kB of its size. The fee changes the inputs that are needed and so the size,
+// so it is recomputed until it covers the transaction.
+func WithFeeRate(feeRate int, create func(fee int) (*transaction.Transaction, error)) (*transaction.Transaction, error) {
+    fee := 0
+    for {
+            tx, err := create(fee)
+            if err != nil {
+                    return nil, err
+            }
+            required := feeRate * ((tx.Size() + 999) / 1000)
+            if required <= fee {
+                    return tx, nil
+            }
+            fee = required
+    }
+}
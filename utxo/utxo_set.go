@@ -0,0 +1,550 @@
+package utxo
+
+import (
+    "bytes"
+    "crypto/ecdsa"
+    "encoding/binary"
+    "encoding/gob"
+    "encoding/hex"
+    "fmt"
+    "log"
+
+    "github.com/boltdb/bolt"
+
+    "blockchain_go/block"
+    "blockchain_go/transaction"
+)
+
+const utxoBucket = "chainstate" // outpoint (txid, vout) -> UTXOEntry
+const undoBucket = "undo"  // block hash -> the outputs the block spent, to disconnect it
+const outpointLength = 32 + 4
+
+// UTXOEntry is an unspent output with what is needed to validate spending it
+type UTXOEntry struct {
+    transaction.TXOutput
+    Height   int  // height of the block that created it
+    Coinbase bool
+}
+
+// Serialize serializes a UTXOEntry
+func (e UTXOEntry) Serialize() []byte {
+    var buff bytes.Buffer
+
+    enc := gob.NewEncoder(&buff)
+    err := enc.Encode(e)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return buff.Bytes()
+}
+
+// DeserializeUTXOEntry deserializes a UTXOEntry
+func DeserializeUTXOEntry(data []byte) UTXOEntry {
+    var entry UTXOEntry
+
+    dec := gob.NewDecoder(bytes.NewReader(data))
+    err := dec.Decode(&entry)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return entry
+}
+
+// Key is the chainstate key of an output: the transaction ID followed by the big-endian output index,
+// so the outputs of a transaction are next to each other in their original order
+func Key(txid []byte, vout int) []byte {
+    key := make([]byte, len(txid)+4)
+    copy(key, txid)
+    binary.BigEndian.PutUint32(key[len(txid):], uint32(vout))
+
+    return key
+}
+
+// splitUTXOKey is the reverse of Key
+func splitUTXOKey(key []byte) ([]byte, int) {
+    txid := key[:len(key)-4]
+
+    return txid, int(binary.BigEndian.Uint32(key[len(txid):]))
+}
+
+// spentOutput is an output spent by a block, as its undo record keeps it
+type spentOutput struct {
+    Txid  []byte
+    Vout  int
+    Entry UTXOEntry
+}
+
+// CreateBuckets creates the buckets of the UTXO set in a new database
+func CreateBuckets(dbTx *bolt.Tx) error {
+    for _, name := range []string{utxoBucket, undoBucket} {
+            _, err := dbTx.CreateBucket([]byte(name))
+            if err != nil {
+                    return err
+            }
+    }
+
+    return nil
+}
+
+// Migrate brings the UTXO set of an existing database up to date.
+// The UTXO set used to be keyed by transaction ID, it has to be rebuilt keyed by outpoint
+// and the undo records written against the old one are dropped. It tells whether the
+// UTXO set needs a Reindex once the DB transaction is committed.
+func Migrate(dbTx *bolt.Tx) (bool, error) {
+    reindex := false
+    if cs := dbTx.Bucket([]byte(utxoBucket)); cs == nil {
+            reindex = true
+    } else if k, _ := cs.Cursor().First(); k != nil && len(k) != outpointLength {
+            reindex = true
+    }
+    if reindex {
+            err := dbTx.DeleteBucket([]byte(undoBucket))
+            if err != nil && err != bolt.ErrBucketNotFound {
+                    return false, err
+            }
+    }
+    _, err := dbTx.CreateBucketIfNotExists([]byte(undoBucket))
+    if err != nil {
+            return false, err
+    }
+
+    return reindex, nil
+}
+
+// FetchEntry looks up an unspent output in the UTXO set within an open DB transaction
+func FetchEntry(dbTx *bolt.Tx, txid []byte, vout int) (UTXOEntry, bool) {
+    entryBytes := dbTx.Bucket([]byte(utxoBucket)).Get(Key(txid, vout))
+    if vout < 0 || entryBytes == nil {
+            return UTXOEntry{}, false
+    }
+
+    return DeserializeUTXOEntry(entryBytes), true
+}
+
+// Chain is what the UTXO set needs from the blockchain it belongs to
+type Chain interface {
+    DB() *bolt.DB
+    FindUTXO() map[string]UTXOEntry
+    GetBestHeight() int
+    RangeBlocks(from, to int, f func(*block.Block) bool) error
+    SignTransaction(tx *transaction.Transaction, privKey ecdsa.PrivateKey) error
+}
+
+type UTXOSet struct {
+    Blockchain Chain
+}
+
+// CountTransactions returns the number of transactions with unspent outputs in the UTXO set
+func (u UTXOSet) CountTransactions() int {
+    db := u.Blockchain.DB()
+    counter := 0
+
+    err := db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(utxoBucket))
+            c := b.Cursor()
+            
+            var last []byte
+            for k, _ := c.First(); k != nil; k, _ = c.Next() {
+                    txid, _ := splitUTXOKey(k)
+                    if !bytes.Equal(txid, last) { // the outputs of a transaction are next to each other
+                            counter++
+                            last = append(last[:0], txid...)
+                    }
+            }
+                                
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+                                            
+    return counter
+}
+
+// TotalValue returns the sum of all unspent outputs, that is the circulating supply
+func (u UTXOSet) TotalValue() int {
+    db := u.Blockchain.DB()
+    total := 0
+
+    err := db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(utxoBucket))
+            c := b.Cursor()
+
+            for k, v := c.First(); k != nil; k, v = c.Next() {
+                    total += DeserializeUTXOEntry(v).Value
+            }
+
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return total
+}
+
+// Reindex rebuilds the UTXO set by scanning the whole chain, along with the address index if there is one.
+// Blocks update the UTXO set as they are added, so this is only for recovering a damaged chainstate.
+func (u UTXOSet) Reindex() {
+    db := u.Blockchain.DB()
+    bucketName := []byte(utxoBucket)
+    // it removes the bucket if it exists
+    err := db.Update(func(tx *bolt.Tx) error {
+            err := tx.DeleteBucket(bucketName)  
+            if err != nil && err != bolt.ErrBucketNotFound {
+                    log.Panic(err)
+            }
+            _, err = tx.CreateBucket(bucketName)
+            if err != nil {
+                    log.Panic(err)
+            }
+
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+            }
+    UTXO := u.Blockchain.FindUTXO()
+    // it gets all unspent outputs from blockchain, and finally it saves the outputs to the bucket.
+    err = db.Update(func(tx *bolt.Tx) error {
+        b := tx.Bucket(bucketName)
+                    
+        for key, entry := range UTXO {
+            err := b.Put([]byte(key), entry.Serialize())
+            if err != nil {
+                    log.Panic(err)
+            }
+        }
+        return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    enabled := false
+    err = db.View(func(tx *bolt.Tx) error {
+            enabled = addrIndexEnabled(tx)
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+    if enabled { // it points into the old UTXO set
+            err = u.ReindexAddresses()
+            if err != nil {
+                    log.Panic(err)
+            }
+    }
+}
+
+// FindSpendableOutputs finds and returns unspent outputs to reference in inputs
+func (u UTXOSet) FindSpendableOutputs(pubkeyHash []byte, amount int) (int, map[string][]int) {
+    unspentOutputs := make(map[string][]int)
+    accumulated := 0
+    db := u.Blockchain.DB()
+
+    err := db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(utxoBucket))
+            c := b.Cursor() // 要遍历键，我们将使用游标Cursor()
+            
+            add := func(k []byte, out UTXOEntry) bool {
+                txid, outIdx := splitUTXOKey(k)
+                txID := hex.EncodeToString(txid)
+                accumulated += out.Value
+                unspentOutputs[txID] = append(unspentOutputs[txID], outIdx)
+                return accumulated < amount
+            }
+            if addressOutputs(tx, pubkeyHash, add) {
+                return nil
+            }
+
+            for k, v := c.First(); k != nil && accumulated < amount; k, v = c.Next() { //First()  移动到第一个健.
+                out := DeserializeUTXOEntry(v)
+                                    
+                if out.IsLockedWithKey(pubkeyHash) {
+                    add(k, out)
+                }
+            }
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+
+    return accumulated, unspentOutputs
+}
+
+// Fee returns what the transaction leaves to the miner: the value of the outputs it
+// spends minus the value of the outputs it creates
+func (u UTXOSet) Fee(t *transaction.Transaction) (int, error) {
+    if t.IsCoinbase() {
+            return 0, nil
+    }
+    fee := 0
+    db := u.Blockchain.DB()
+
+    err := db.View(func(tx *bolt.Tx) error {
+            b := tx.Bucket([]byte(utxoBucket))
+
+            for _, vin := range t.Vin {
+                    entryBytes := b.Get(Key(vin.Txid, vin.Vout))
+                    if vin.Vout < 0 || entryBytes == nil {
+                            return fmt.Errorf("output %x:%d is not in the UTXO set", vin.Txid, vin.Vout)
+                    }
+                    fee += DeserializeUTXOEntry(entryBytes).Value
+            }
+            return nil
+    })
+    if err != nil {
+            return 0, err
+    }
+    for _, out := range t.Vout {
+            fee -= out.Value
+    }
+
+    return fee, nil
+}
+
+// FindUTXO finds UTXO for a public key hash
+func (u UTXOSet) FindUTXO(pubKeyHash []byte) []transaction.TXOutput {
+    var UTXOs []transaction.TXOutput
+    db := u.Blockchain.DB()
+
+    err := db.View(func(tx *bolt.Tx) error {
+            found := addressOutputs(tx, pubKeyHash, func(k []byte, entry UTXOEntry) bool {
+                UTXOs = append(UTXOs, entry.TXOutput)
+                return true
+            })
+            if found {
+                return nil
+            }
+
+            b := tx.Bucket([]byte(utxoBucket))
+            c := b.Cursor()
+            
+            for k, v := c.First(); k != nil; k, v = c.Next() {
+                entry := DeserializeUTXOEntry(v)
+                            
+                if entry.IsLockedWithKey(pubKeyHash) {
+                    UTXOs = append(UTXOs, entry.TXOutput)
+                }
+            }
+            return nil
+    })
+    if err != nil {
+        log.Panic(err)
+    }
+    return UTXOs
+}
+
+// Unspent is an unspent output along with the outpoint spending it refers to
+type Unspent struct {
+    Txid []byte
+    Vout int
+    UTXOEntry
+}
+
+// ListUnspent returns the unspent outputs locked with the public key hash, in outpoint order
+func (u UTXOSet) ListUnspent(pubKeyHash []byte) []Unspent {
+    var unspent []Unspent
+    db := u.Blockchain.DB()
+
+    err := db.View(func(tx *bolt.Tx) error {
+            add := func(k []byte, entry UTXOEntry) bool {
+                txid, vout := splitUTXOKey(k)
+                unspent = append(unspent, Unspent{append([]byte{}, txid...), vout, entry}) // keys are only valid inside the transaction
+                return true
+            }
+            if addressOutputs(tx, pubKeyHash, add) {
+                return nil
+            }
+
+            c := tx.Bucket([]byte(utxoBucket)).Cursor()
+            for k, v := c.First(); k != nil; k, v = c.Next() {
+                entry := DeserializeUTXOEntry(v)
+                if entry.IsLockedWithKey(pubKeyHash) {
+                    add(k, entry)
+                }
+            }
+            return nil
+    })
+    if err != nil {
+        log.Panic(err)
+    }
+    return unspent
+}
+
+// Update updates the UTXO set with transactions from the Block
+// The Block is considered to be the tip of a blockchain
+func (u UTXOSet) Update(b *block.Block) {
+    db := u.Blockchain.DB()
+
+    err := db.Update(func(tx *bolt.Tx) error {
+            ConnectBlock(tx, b)
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// Disconnect reverts Update for the tip block, using the undo record saved when it was connected
+func (u UTXOSet) Disconnect(b *block.Block) {
+    db := u.Blockchain.DB()
+
+    err := db.Update(func(tx *bolt.Tx) error {
+            DisconnectBlock(tx, b, nil)
+            return nil
+    })
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// ConnectBlock removes the outputs spent by the block and adds the ones it creates, within an open DB transaction.
+// The outputs it spends are kept in the block's undo record for DisconnectBlock.
+func ConnectBlock(dbTx *bolt.Tx, block *block.Block) {
+    b := dbTx.Bucket([]byte(utxoBucket))
+    var undo []spentOutput
+    for _, tx := range block.Transactions {
+        if tx.IsCoinbase() == false {
+            for _, vin := range tx.Vin {
+                    key := Key(vin.Txid, vin.Vout)  // Txid means the previous transaction ID
+                    entry := DeserializeUTXOEntry(b.Get(key))
+                    undo = append(undo, spentOutput{vin.Txid, vin.Vout, entry})
+
+                    err := b.Delete(key)
+                    if err != nil {
+                            log.Panic(err)
+                    }
+                    addrDelete(dbTx, addrUTXOBucket, entry.PubKeyHash, key)
+            }
+        }
+        for outIdx, out := range tx.Vout {  // add all the output of this transaction
+                key := Key(tx.ID, outIdx)
+                entry := UTXOEntry{out, block.Height, tx.IsCoinbase()}
+                err := b.Put(key, entry.Serialize())
+                if err != nil {
+                        log.Panic(err)
+                }
+                addrPut(dbTx, addrUTXOBucket, out.PubKeyHash, key)
+        }
+        indexHistory(dbTx, block.Height, tx)
+    }
+
+    err := dbTx.Bucket([]byte(undoBucket)).Put(block.Hash, gobEncode(undo))
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// FindFunc looks up a transaction the block with hash from may spend, along with the block it is in.
+// Both are nil if it isn't found.
+type FindFunc func(from, txid []byte) (*block.Block, *transaction.Transaction)
+
+// DisconnectBlock reverts ConnectBlock for a block that is being removed from the tip.
+// Transactions are undone in reverse order: their outputs are dropped and the
+// outputs they spent are put back as the undo record has them. Blocks without
+// an undo record need find to get the outputs back from the chain.
+func DisconnectBlock(dbTx *bolt.Tx, block *block.Block, find FindFunc) {
+    undoData := dbTx.Bucket([]byte(undoBucket)).Get(block.Hash)
+    if undoData == nil {
+            if find == nil {
+                    log.Panicf("ERROR: Block %x has no undo record", block.Hash)
+            }
+            disconnectWithoutUndo(dbTx, block, find)
+            return
+    }
+    var undo []spentOutput
+    err := gob.NewDecoder(bytes.NewReader(undoData)).Decode(&undo)
+    if err != nil {
+            log.Panic(err)
+    }
+
+    b := dbTx.Bucket([]byte(utxoBucket))
+    for i := len(block.Transactions) - 1; i >= 0; i-- {
+        tx := block.Transactions[i]
+
+        unindexHistory(dbTx, block.Height, tx)
+        for outIdx, out := range tx.Vout {
+                key := Key(tx.ID, outIdx)
+                err := b.Delete(key)
+                if err != nil {
+                        log.Panic(err)
+                }
+                addrDelete(dbTx, addrUTXOBucket, out.PubKeyHash, key)
+        }
+        if tx.IsCoinbase() {
+                continue
+        }
+
+        for range tx.Vin {
+                spent := undo[len(undo)-1]
+                undo = undo[:len(undo)-1]
+
+                key := Key(spent.Txid, spent.Vout)
+                err = b.Put(key, spent.Entry.Serialize())
+                if err != nil {
+                        log.Panic(err)
+                }
+                addrPut(dbTx, addrUTXOBucket, spent.Entry.PubKeyHash, key)
+        }
+    }
+
+    err = dbTx.Bucket([]byte(undoBucket)).Delete(block.Hash)
+    if err != nil {
+            log.Panic(err)
+    }
+}
+
+// disconnectWithoutUndo disconnects a block whose undo record is missing, as for blocks
+// connected before the UTXO set was keyed by outpoint. The outputs it spent are restored
+// from the transactions that created them, which are searched for back from the block.
+func disconnectWithoutUndo(dbTx *bolt.Tx, block *block.Block, find FindFunc) {
+    b := dbTx.Bucket([]byte(utxoBucket))
+    for i := len(block.Transactions) - 1; i >= 0; i-- {
+        tx := block.Transactions[i]
+
+        unindexHistory(dbTx, block.Height, tx)
+        for outIdx, out := range tx.Vout {
+                key := Key(tx.ID, outIdx)
+                err := b.Delete(key)
+                if err != nil {
+                        log.Panic(err)
+                }
+                addrDelete(dbTx, addrUTXOBucket, out.PubKeyHash, key)
+        }
+        if tx.IsCoinbase() {
+                continue
+        }
+
+        for _, vin := range tx.Vin {
+                prevBlock, prevTx := find(block.Hash, vin.Txid)
+                if prevTx == nil {
+                        log.Panic("ERROR: Transaction is not found")
+                }
+                entry := UTXOEntry{prevTx.Vout[vin.Vout], prevBlock.Height, prevTx.IsCoinbase()}
+
+                key := Key(vin.Txid, vin.Vout)
+                err := b.Put(key, entry.Serialize())
+                if err != nil {
+                        log.Panic(err)
+                }
+                addrPut(dbTx, addrUTXOBucket, entry.PubKeyHash, key)
+        }
+    }
+}
+
+func gobEncode(data interface{}) []byte {
+    var buff bytes.Buffer
+
+    enc := gob.NewEncoder(&buff)
+    err := enc.Encode(data)
+    if err != nil {
+        log.Panic(err)
+    }
+
+    return buff.Bytes()
+}
@@ -0,0 +1,112 @@
+package utxo_test
+
+import (
+    "encoding/hex"
+    "fmt"
+    "path/filepath"
+    "testing"
+
+    "github.com/boltdb/bolt"
+    "github.com/stretchr/testify/assert"
+
+    "blockchain_go/block"
+    "blockchain_go/chain"
+    "blockchain_go/transaction"
+    "blockchain_go/utxo"
+    "blockchain_go/wallet"
+)
+
+// spendCoinbase creates a transaction moving the coinbase output of block to address
+func spendCoinbase(bc *chain.Blockchain, w *wallet.Wallet, block *block.Block, to string, amount, fee int) *transaction.Transaction {
+    coinbase := block.Transactions[0]
+    change := coinbase.Vout[0].Value - amount - fee
+    inputs := []transaction.TXInput{{Txid: coinbase.ID, Vout: 0, PubKey: w.PublicKey}}
+    outputs := []transaction.TXOutput{*transaction.NewTXOutput(amount, to), *transaction.NewTXOutput(change, string(w.GetAddress()))}
+
+    tx := transaction.Transaction{Vin: inputs, Vout: outputs}
+    err := bc.SignTransaction(&tx, w.PrivateKey)
+    if err != nil {
+            panic(err)
+    }
+    tx.ID = tx.Hash()
+
+    return &tx
+}
+
+// createBlockchain and mineBlock stop the test when the chain can't be created or the block added
+func createBlockchain(t *testing.T, address, dbFile string) *chain.Blockchain {
+    bc, err := chain.CreateBlockchainFile(dbFile, address)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return bc
+}
+
+func mineBlock(t *testing.T, bc *chain.Blockchain, txs []*transaction.Transaction) *block.Block {
+    block, _, _, err := bc.MineBlock(txs, nil)
+    if err != nil {
+            t.Fatal(err)
+    }
+    return block
+}
+
+// chainstate copies the UTXO set out of the DB
+func chainstate(bc *chain.Blockchain) map[string][]byte {
+    entries := make(map[string][]byte)
+    err := bc.DB().View(func(tx *bolt.Tx) error {
+            return tx.Bucket([]byte(utxo.UTXOBucket)).ForEach(func(k, v []byte) error {
+                    entries[fmt.Sprintf("%x", k)] = append([]byte{}, v...)
+                    return nil
+            })
+    })
+    if err != nil {
+            panic(err)
+    }
+
+    return entries
+}
+
+func TestUTXOSetUpdates(t *testing.T) {
+    dbFile := filepath.Join(t.TempDir(), "blockchain.db")
+
+    miner := wallet.NewWallet()
+    receiver := wallet.NewWallet()
+    to := string(receiver.GetAddress())
+    bc := createBlockchain(t, string(miner.GetAddress()), dbFile)
+    defer bc.Close()
+    UTXOSet := utxo.UTXOSet{Blockchain: bc}
+
+    first := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 1, 0)})
+    mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 2, 0)})
+    before := chainstate(bc)
+
+    spend := spendCoinbase(bc, miner, first, to, 5, 1)
+    block := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 3, 1), spend})
+    after := chainstate(bc)
+    assert.NotEqual(t, before, after)
+
+    UTXOSet.Reindex()
+    assert.Equal(t, after, chainstate(bc), "Mined blocks update the UTXO set as a reindex would")
+
+    // spend the first output, the change stays at index 1
+    payment := transaction.Transaction{Vin: []transaction.TXInput{{Txid: spend.ID, Vout: 0, PubKey: receiver.PublicKey}}, Vout: []transaction.TXOutput{*transaction.NewTXOutput(spend.Vout[0].Value, string(miner.GetAddress()))}}
+    assert.Nil(t, bc.SignTransaction(&payment, receiver.PrivateKey))
+    payment.ID = payment.Hash()
+    tip := mineBlock(t, bc, []*transaction.Transaction{transaction.NewCoinbaseTX(string(miner.GetAddress()), "", 4, 0), &payment})
+
+    _, outputs := UTXOSet.FindSpendableOutputs(wallet.HashPubKey(miner.PublicKey), 1000)
+    assert.Equal(t, []int{1}, outputs[hex.EncodeToString(spend.ID)], "Outputs keep their index when others are spent")
+    change := utxo.DeserializeUTXOEntry(chainstate(bc)[fmt.Sprintf("%x", utxo.Key(spend.ID, 1))])
+    assert.Equal(t, utxo.UTXOEntry{TXOutput: spend.Vout[1], Height: 3, Coinbase: false}, change, "Entries keep the height they were created at")
+    assert.True(t, utxo.DeserializeUTXOEntry(chainstate(bc)[fmt.Sprintf("%x", utxo.Key(tip.Transactions[0].ID, 0))]).Coinbase)
+
+    UTXOSet.Disconnect(tip)
+    assert.Equal(t, after, chainstate(bc), "Disconnecting puts spent outputs back in place")
+    UTXOSet.Disconnect(block)
+    assert.Equal(t, before, chainstate(bc), "Disconnecting restores the UTXO set from the undo record")
+    err := bc.DB().View(func(tx *bolt.Tx) error {
+            assert.Nil(t, tx.Bucket([]byte(utxo.UndoBucket)).Get(block.Hash), "The undo record goes with the block")
+            return nil
+    })
+    assert.Nil(t, err)
+}
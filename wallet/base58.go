@@ -1,4 +1,4 @@
-package main
+package wallet
 
 import (
     "bytes"
@@ -45,3 +45,9 @@ func Base58Decode(input []byte) []byte {
     return decoded
 }
 
+// ReverseBytes reverses a byte array in place
+func ReverseBytes(s []byte) {
+    for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+            s[i], s[j] = s[j], s[i]
+    }
+}
@@ -1,4 +1,4 @@
-package main
+package wallet
 
 import (
     "encoding/hex"
@@ -1,4 +1,4 @@
-package main
+package wallet
 
 import (
     "crypto/ecdsa"
@@ -6,8 +6,11 @@ import (
     "crypto/rand"
     "crypto/sha256"
     "bytes"
+    "encoding/gob"
+    "errors"
     "golang.org/x/crypto/ripemd160"
     "log"
+    "math/big"
 )
 
 const version = byte(0x00)
@@ -32,15 +35,64 @@ func newKeyPair() (ecdsa.PrivateKey, []byte) {
     if err != nil {
         log.Panic(err)
     }
-    pubKey := append(private.PublicKey.X.Bytes(), private.PublicKey.Y.Bytes()...)  //public key is a combination of X, Y coordinates
+    pubKey := make([]byte, 64)  //public key is a combination of X, Y coordinates, 32 bytes each
+    private.PublicKey.X.FillBytes(pubKey[:32])
+    private.PublicKey.Y.FillBytes(pubKey[32:])
 
     return *private, pubKey
 }
 
+// walletData is how a wallet is stored. The curve of an ecdsa key can't be gob encoded,
+// so only the private scalar is kept and the rest of the key is derived from it.
+type walletData struct {
+    D         []byte
+    PublicKey []byte
+}
+
+// GobEncode stores the wallet as walletData
+func (w Wallet) GobEncode() ([]byte, error) {
+    var buff bytes.Buffer
+
+    err := gob.NewEncoder(&buff).Encode(walletData{w.PrivateKey.D.Bytes(), w.PublicKey})
+    return buff.Bytes(), err
+}
+
+// GobDecode restores a wallet stored by GobEncode
+func (w *Wallet) GobDecode(data []byte) error {
+    var stored walletData
+
+    err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored)
+    if err != nil {
+            return err
+    }
+    private, err := privateKeyFromScalar(new(big.Int).SetBytes(stored.D))
+    if err != nil {
+            return err
+    }
+    w.PrivateKey = private
+    w.PublicKey = stored.PublicKey
+
+    return nil
+}
+
+// privateKeyFromScalar rebuilds a P256 private key from its scalar
+func privateKeyFromScalar(d *big.Int) (ecdsa.PrivateKey, error) {
+    curve := elliptic.P256()
+    if d.Sign() <= 0 || d.Cmp(curve.Params().N) >= 0 {
+            return ecdsa.PrivateKey{}, errors.New("invalid private key")
+    }
+    x, y := curve.ScalarBaseMult(d.Bytes())
+
+    return ecdsa.PrivateKey{PublicKey: ecdsa.PublicKey{Curve: curve, X: x, Y: y}, D: d}, nil
+}
+
 // GetAddress returns wallet address, look at address-generation-scheme.png
 func (w Wallet) GetAddress() []byte { 
-    pubKeyHash := HashPubKey(w.PublicKey)
+    return PubKeyHashToAddress(HashPubKey(w.PublicKey))
+}
 
+// PubKeyHashToAddress returns the address that outputs locked with pubKeyHash pay to
+func PubKeyHashToAddress(pubKeyHash []byte) []byte {
     versionedPayload := append([]byte{version}, pubKeyHash...)
     checksum := checksum(versionedPayload)
 
@@ -67,6 +119,9 @@ func HashPubKey(pubKey []byte) []byte {
 // ValidateAddress check if address if valid
 func ValidateAddress(address string) bool {
     pubKeyHash := Base58Decode([]byte(address))
+    if len(pubKeyHash) < 1+addressChecksumLen {
+            return false
+    }
     actualChecksum := pubKeyHash[len(pubKeyHash)-addressChecksumLen:]
     version := pubKeyHash[0]
     pubKeyHash = pubKeyHash[1 : len(pubKeyHash)-addressChecksumLen]
@@ -0,0 +1,150 @@
+package wallet
+
+import (
+    "bytes"
+    "encoding/gob"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "math/big"
+    "os"
+)
+
+const WalletFile = "wallet_%s.dat"
+
+// ErrUnknownAddress is returned by GetWallet for an address that has no key in the wallet file
+var ErrUnknownAddress = errors.New("no key for the address in the wallet file")
+
+// Wallets is what a wallet file holds, gob encoded. Each Wallet is stored as its private
+// scalar and public key (see walletData), files written by older versions with the whole
+// ecdsa key are still read and are rewritten in the new format the next time they're saved.
+// Older versions can't read files in the new format.
+type Wallets struct {
+    Wallets map[string]*Wallet
+}
+
+// NewWallets creates Wallets and fills it from a file if it exists
+func NewWallets(nodeID string) (*Wallets, error) {
+    return NewWalletsFile(fmt.Sprintf(WalletFile, nodeID))
+}
+
+// NewWalletsFile is NewWallets with the path of the wallet file rather than a node ID
+func NewWalletsFile(walletFile string) (*Wallets, error) {
+    wallets := Wallets{}
+    wallets.Wallets = make(map[string]*Wallet)
+
+    err := wallets.loadFile(walletFile)
+
+    return &wallets, err
+}
+
+// CreateWallet adds a Wallet to Wallets
+func (ws *Wallets) CreateWallet() string {
+    wallet := NewWallet()
+    address := fmt.Sprintf("%s", wallet.GetAddress())
+
+    ws.Wallets[address] = wallet
+
+    return address
+}
+
+// GetAddresses returns an array of addresses stored in the wallet file
+func (ws *Wallets) GetAddresses() []string {
+    var addresses []string
+
+    for address := range ws.Wallets {
+            addresses = append(addresses, address)
+    }
+    return addresses
+}
+
+// GetWallet returns a Wallet by its address, or ErrUnknownAddress if the file has no key for it
+func (ws *Wallets) GetWallet(address string) (*Wallet, error) {
+    wallet, ok := ws.Wallets[address]
+    if !ok {
+            return nil, fmt.Errorf("%w: %s", ErrUnknownAddress, address)
+    }
+    return wallet, nil
+}
+
+// LoadFromFile reads the wallets of the node. It returns an error satisfying os.IsNotExist
+// when the node has no wallet file yet.
+func (ws *Wallets) LoadFromFile(nodeID string) error {
+    return ws.loadFile(fmt.Sprintf(WalletFile, nodeID))
+}
+
+func (ws *Wallets) loadFile(walletFile string) error {
+    if _, err := os.Stat(walletFile); os.IsNotExist(err) {
+            return err
+    }
+    fileContent, err := ioutil.ReadFile(walletFile)
+    if err != nil {
+            return err
+    }
+    var wallets Wallets
+    decoder := gob.NewDecoder(bytes.NewReader(fileContent))
+    err = decoder.Decode(&wallets)
+    if err != nil {
+            wallets.Wallets, err = decodeLegacyWallets(fileContent)
+            if err != nil {
+                    return fmt.Errorf("can't read %s: %s", walletFile, err)
+            }
+    }
+    ws.Wallets = wallets.Wallets
+    return nil
+}
+
+// legacyWallets is the layout of wallet files written with the whole ecdsa key.
+// Only the fields needed to rebuild the keys are read, the curve is always P256.
+type legacyWallets struct {
+    Wallets map[string]*struct {
+            PrivateKey struct {
+                    D *big.Int
+            }
+            PublicKey []byte
+    }
+}
+
+func decodeLegacyWallets(data []byte) (map[string]*Wallet, error) {
+    var legacy legacyWallets
+
+    err := gob.NewDecoder(bytes.NewReader(data)).Decode(&legacy)
+    if err != nil {
+            return nil, err
+    }
+
+    wallets := make(map[string]*Wallet)
+    for address, w := range legacy.Wallets {
+            if w.PrivateKey.D == nil {
+                    return nil, fmt.Errorf("wallet %s has no private key", address)
+            }
+            private, err := privateKeyFromScalar(w.PrivateKey.D)
+            if err != nil {
+                    return nil, err
+            }
+            wallets[address] = &Wallet{private, w.PublicKey}
+    }
+    return wallets, nil
+}
+
+// SaveToFile saves wallets to a file
+func (ws Wallets) SaveToFile(nodeID string) error {
+    return ws.SaveToPath(fmt.Sprintf(WalletFile, nodeID))
+}
+
+// SaveToPath is SaveToFile with the path of the wallet file rather than a node ID.
+// The file holds private keys, so only its owner may read it.
+func (ws Wallets) SaveToPath(walletFile string) error {
+    var content bytes.Buffer
+
+    encoder := gob.NewEncoder(&content)
+    err := encoder.Encode(ws)
+    if err != nil {
+            return err
+    }
+    err = ioutil.WriteFile(walletFile, content.Bytes(), 0600)
+    if err != nil {
+            return err
+    }
+    return os.Chmod(walletFile, 0600) // WriteFile keeps the mode of a file that already exists
+}
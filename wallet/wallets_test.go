@@ -0,0 +1,51 @@
+package wallet
+
+import (
+    "errors"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/stretchr/testify/assert"
+)
+
+func TestWalletsFile(t *testing.T) {
+    file := filepath.Join(t.TempDir(), "wallet.dat")
+
+    wallets, err := NewWalletsFile(file)
+    assert.True(t, os.IsNotExist(err), "There is no wallet file at first")
+    address := wallets.CreateWallet()
+    assert.Nil(t, wallets.SaveToPath(file))
+    info, err := os.Stat(file)
+    assert.Nil(t, err)
+    assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Only the owner can read the keys")
+
+    loaded, err := NewWalletsFile(file)
+    assert.Nil(t, err)
+    saved, err := wallets.GetWallet(address)
+    assert.Nil(t, err)
+    w, err := loaded.GetWallet(address)
+    assert.Nil(t, err)
+    assert.Equal(t, saved, w, "Wallets are saved with their keys")
+    _, err = loaded.GetWallet(string(NewWallet().GetAddress()))
+    assert.True(t, errors.Is(err, ErrUnknownAddress))
+
+    // wallet.dat was written when the whole ecdsa key was gob encoded
+    data, err := ioutil.ReadFile("testdata/wallet.dat")
+    assert.Nil(t, err)
+    assert.Nil(t, ioutil.WriteFile(file, data, 0644))
+    legacy, err := NewWalletsFile(file)
+    assert.Nil(t, err)
+    assert.Len(t, legacy.GetAddresses(), 2)
+    for _, address := range legacy.GetAddresses() {
+            w, err := legacy.GetWallet(address)
+            assert.Nil(t, err)
+            assert.Equal(t, address, string(w.GetAddress()))
+            assert.Equal(t, w.PublicKey, append(w.PrivateKey.X.Bytes(), w.PrivateKey.Y.Bytes()...), "The key is rebuilt from the private scalar")
+    }
+
+    assert.Nil(t, ioutil.WriteFile(file, []byte("garbage"), 0644))
+    _, err = NewWalletsFile(file)
+    assert.NotNil(t, err, "A broken wallet file is an error, not a panic")
+}